@@ -0,0 +1,13 @@
+package apperrors
+
+type SQLFailure struct {
+	Err error
+}
+
+func (e *SQLFailure) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SQLFailure) Unwrap() error {
+	return e.Err
+}
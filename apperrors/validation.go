@@ -0,0 +1,13 @@
+package apperrors
+
+type Validation struct {
+	Err error
+}
+
+func (e *Validation) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Validation) Unwrap() error {
+	return e.Err
+}
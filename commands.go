@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/msg"
+	"github.com/easynow112/dbkit/source"
+	"github.com/easynow112/dbkit/version"
+)
+
+type handler func(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error
+
+type command struct {
+	name        string
+	help        string
+	run         handler
+	noConfig    bool
+	noSource    bool
+	subcommands []*command
+}
+
+var rootCommand = &command{
+	help: msg.Usage,
+	subcommands: []*command{
+		{
+			name: "migrate",
+			help: msg.Migrate.Help(),
+			subcommands: []*command{
+				{name: "new", help: msg.MigrateNew.Help(), run: handleMigrateNew},
+				{name: "up", help: msg.MigrateUp.Help(), run: handleMigrateUp},
+				{name: "down", help: msg.MigrateDown.Help(), run: handleMigrateDown},
+				{name: "goto", help: msg.MigrateGoto.Help(), run: handleMigrateGoto},
+				{name: "status", help: msg.MigrateStatus.Help(), run: handleMigrateStatus},
+				{name: "history", help: msg.MigrateHistory.Help(), run: handleMigrateHistory},
+				{name: "repair", help: msg.MigrateRepair.Help(), run: handleMigrateRepair},
+			},
+		},
+		{
+			name: "lock",
+			help: msg.Lock.Help(),
+			subcommands: []*command{
+				{name: "status", help: msg.LockStatus.Help(), run: handleLockStatus},
+				{name: "release", help: msg.LockRelease.Help(), run: handleLockRelease},
+			},
+		},
+		{
+			name:     "seed",
+			help:     msg.Seeds.Help(),
+			run:      handleSeed,
+			noSource: true,
+			subcommands: []*command{
+				{name: "new", help: msg.SeedNew.Help(), run: handleSeedNew, noSource: true},
+			},
+		},
+		{name: "version", help: msg.Version.Help(), run: handleVersion, noConfig: true},
+	},
+}
+
+func resolveCommand(args []string) *command {
+	cmd := rootCommand
+	for _, arg := range args[1:] {
+		next := cmd.subcommand(arg)
+		if next == nil {
+			break
+		}
+		cmd = next
+	}
+	return cmd
+}
+
+func (cmd *command) subcommand(name string) *command {
+	for _, subcommand := range cmd.subcommands {
+		if subcommand.name == name {
+			return subcommand
+		}
+	}
+	return nil
+}
+
+func extractHelpFlag(args []string) (help bool, rest []string) {
+	rest = make([]string, 0, len(args))
+	for _, arg := range args {
+		switch arg {
+		case "--help", "-help", "-h":
+			help = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return help, rest
+}
+
+func extractDurationFlag(args []string, name string) (value string, found bool, rest []string, err error) {
+	value, found, rest, err = extractFlag(args, name)
+	if err != nil {
+		return "", false, nil, &apperrors.InvalidArgs{
+			Args: args,
+			Hint: err.Error(),
+		}
+	}
+	if !found {
+		return "", false, rest, nil
+	}
+	if _, err := config.ParseDuration(value); err != nil {
+		return "", false, nil, &apperrors.InvalidArgs{
+			Args: args,
+			Hint: fmt.Sprintf("--%s: %v", name, err),
+		}
+	}
+	return value, true, rest, nil
+}
+
+func extractOverrideFlag(args []string, name string) (value string, rest []string, err error) {
+	value, found, rest, err := extractFlag(args, name)
+	if err == nil && found && value == "" {
+		err = fmt.Errorf("Flag --%s requires a non-empty value", name)
+	}
+	if err != nil {
+		return "", nil, &apperrors.InvalidArgs{
+			Args: args,
+			Hint: err.Error(),
+		}
+	}
+	return value, rest, nil
+}
+
+func handleVersion(ctx context.Context, args []string, out *output, _ *config.Config, _ source.StoreFactory, _ db.DBFactory) error {
+	if len(args) != 2 {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.Version.Help(),
+		}
+	}
+	out.printVersion(version.String())
+	return nil
+}
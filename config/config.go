@@ -1,12 +1,31 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type Migrations struct {
-	Up   string `json:"up"`
-	Down string `json:"down"`
+	Up       string `json:"up"`
+	Down     string `json:"down"`
+	Combined string `json:"-"`
+}
+
+func (m *Migrations) UnmarshalJSON(data []byte) error {
+	var combined string
+	if err := json.Unmarshal(data, &combined); err == nil {
+		*m = Migrations{Combined: combined}
+		return nil
+	}
+	type migrations Migrations
+	var split migrations
+	if err := json.Unmarshal(data, &split); err != nil {
+		return fmt.Errorf("active.source.migrations must be a source name or an object with up and down source names: %v", err)
+	}
+	*m = Migrations(split)
+	return nil
 }
 
 type Source struct {
@@ -20,15 +39,45 @@ type ActiveConfig struct {
 	Environment string `json:"environment"`
 }
 
+func ParseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s', expected a value such as \"30s\" or \"5m\"", value)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("invalid duration '%s', expected a value that is not negative", value)
+	}
+	return parsed, nil
+}
+
+const DefaultTimeout = 30 * time.Second
+
+type Settings struct {
+	AllowOutOfOrder bool   `json:"allowOutOfOrder"`
+	LockTimeout     string `json:"lockTimeout"`
+	Timeout         string `json:"timeout"`
+}
+
+func (s Settings) CommandTimeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	return ParseDuration(s.Timeout)
+}
+
 type Config struct {
 	Active       ActiveConfig
 	Environments map[string]string
 	Databases    map[string]DriverConfig
 	Sources      map[string]DriverConfig
+	Settings     Settings
 	Global       GlobalConfig
 }
 
-type ConfigFactory func() (*Config, error)
+type ConfigFactory func(overrides Overrides) (*Config, error)
 
 func (c *Config) validate() error {
 	// Environments
@@ -44,16 +93,30 @@ func (c *Config) validate() error {
 	}
 
 	// Sources
-	if err := validateDriverConfig("active.source.migrations.up", c.Sources, c.Active.Source.Migrations.Up); err != nil {
-		return err
-	}
-	if err := validateDriverConfig("active.source.migrations.down", c.Sources, c.Active.Source.Migrations.Down); err != nil {
-		return err
+	if c.Active.Source.Migrations.Combined != "" {
+		if err := validateDriverConfig("active.source.migrations", c.Sources, c.Active.Source.Migrations.Combined); err != nil {
+			return err
+		}
+	} else {
+		if err := validateDriverConfig("active.source.migrations.up", c.Sources, c.Active.Source.Migrations.Up); err != nil {
+			return err
+		}
+		if err := validateDriverConfig("active.source.migrations.down", c.Sources, c.Active.Source.Migrations.Down); err != nil {
+			return err
+		}
 	}
 	if err := validateDriverConfig("active.source.seeds", c.Sources, c.Active.Source.Seeds); err != nil {
 		return err
 	}
 
+	// Settings
+	if _, err := ParseDuration(c.Settings.LockTimeout); err != nil {
+		return fmt.Errorf("settings.lockTimeout: %v", err)
+	}
+	if _, err := c.Settings.CommandTimeout(); err != nil {
+		return fmt.Errorf("settings.timeout: %v", err)
+	}
+
 	// Global
 	if err := c.Global.validate(); err != nil {
 		return err
@@ -81,6 +144,34 @@ type DriverConfig struct {
 	Config map[string]string `json:"config"`
 }
 
+func (p *DriverConfig) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Driver string         `json:"driver"`
+		Config map[string]any `json:"config"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	p.Driver = raw.Driver
+	p.Config = nil
+	if raw.Config != nil {
+		p.Config = make(map[string]string, len(raw.Config))
+	}
+	for key, value := range raw.Config {
+		switch value := value.(type) {
+		case string:
+			p.Config[key] = value
+		case json.Number, bool:
+			p.Config[key] = fmt.Sprint(value)
+		default:
+			return fmt.Errorf("config.%s must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
 func (p *DriverConfig) validate() error {
 	if p.Driver == "" {
 		return fmt.Errorf("driver is required")
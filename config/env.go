@@ -2,48 +2,61 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
 )
 
+// Matches $$, ${VAR}, ${VAR:-default} and ${VAR:?message}
+var envRegExp = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)([^}]*))?\}`)
+
+func expandEnvEnvironments(config *Config) error {
+	return expandEnvMap("environments", config.Environments)
+}
+
 func expandEnvConfig(config *Config) error {
 
-	if err := expandEnvStrings(
-		&config.Active.Source.Migrations.Up,
-		&config.Active.Source.Migrations.Down,
-		&config.Active.Source.Seeds,
-		&config.Active.Database,
-	); err != nil {
+	if err := expandEnvStrings(map[string]*string{
+		"active.source.migrations.up":   &config.Active.Source.Migrations.Up,
+		"active.source.migrations.down": &config.Active.Source.Migrations.Down,
+		"active.source.migrations":      &config.Active.Source.Migrations.Combined,
+		"active.source.seeds":           &config.Active.Source.Seeds,
+		"active.database":               &config.Active.Database,
+	}); err != nil {
 		return err
 	}
 
-	if err := expandEnvDriverConfigMap(config.Sources); err != nil {
+	if err := expandEnvDriverConfigMap("sources", config.Sources); err != nil {
 		return err
 	}
 
-	if err := expandEnvDriverConfigMap(config.Databases); err != nil {
+	if err := expandEnvDriverConfigMap("databases", config.Databases); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func expandEnvDriverConfigMap(input map[string]DriverConfig) error {
-	for key, value := range input {
-		if err := expandEnv(&value.Driver); err != nil {
+func expandEnvDriverConfigMap(prefix string, input map[string]DriverConfig) error {
+	for _, name := range slices.Sorted(maps.Keys(input)) {
+		value := input[name]
+		if err := expandEnv(prefix+"."+name+".driver", &value.Driver); err != nil {
 			return err
 		}
-		if err := expandEnvMap(value.Config); err != nil {
+		if err := expandEnvMap(prefix+"."+name+".config", value.Config); err != nil {
 			return err
 		}
-		input[key] = value
+		input[name] = value
 	}
 	return nil
 }
 
-func expandEnvMap(input map[string]string) error {
-	for key, value := range input {
-		if err := expandEnv(&value); err != nil {
+func expandEnvMap(prefix string, input map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(input)) {
+		value := input[key]
+		if err := expandEnv(prefix+"."+key, &value); err != nil {
 			return err
 		}
 		input[key] = value
@@ -51,44 +64,54 @@ func expandEnvMap(input map[string]string) error {
 	return nil
 }
 
-func expandEnvStrings(strings ...*string) error {
-	for _, s := range strings {
-		if err := expandEnv(s); err != nil {
+func expandEnvStrings(fields map[string]*string) error {
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if err := expandEnv(key, fields[key]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func expandEnv(input *string) error {
+func expandEnv(key string, input *string) error {
 	if input == nil {
-		return fmt.Errorf("env string pointer is nil")
+		return fmt.Errorf("%s: env string pointer is nil", key)
 	}
 
-	var err error
-
-	envRegExp := regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`) // Regex to match ${VAR_NAME}
-
 	var missing []string
+	var failed []string
 	expanded := envRegExp.ReplaceAllStringFunc(*input, func(match string) string {
-		submatches := envRegExp.FindStringSubmatch(match)
-		if len(submatches) < 2 {
-			err = fmt.Errorf("malformed regexp submatches: %s", submatches)
-			return ""
+		if match == "$$" {
+			return "$"
 		}
-		envVar := submatches[1]
+		submatches := envRegExp.FindStringSubmatch(match)
+		envVar, operator, operand := submatches[1], submatches[2], submatches[3]
 		value, ok := os.LookupEnv(envVar)
-		if !ok {
-			missing = append(missing, envVar)
-			return ""
+		switch operator {
+		case ":-":
+			if value == "" {
+				return operand
+			}
+		case ":?":
+			if value == "" {
+				if operand == "" {
+					operand = "is not set"
+				}
+				failed = append(failed, fmt.Sprintf("%s %s", envVar, operand))
+			}
+		default:
+			if !ok {
+				missing = append(missing, envVar)
+			}
 		}
 		return value
 	})
 
+	if len(failed) > 0 {
+		return fmt.Errorf("%s: %s", key, strings.Join(failed, ", "))
+	}
 	if len(missing) > 0 {
-		return fmt.Errorf("missing environment variable(s): %v", missing)
-	} else if err != nil {
-		return err
+		return fmt.Errorf("%s: missing environment variable(s): %s", key, strings.Join(missing, ", "))
 	}
 
 	*input = expanded
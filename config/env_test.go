@@ -0,0 +1,160 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("DBKIT_TEST_HOST", "db.internal")
+	t.Setenv("DBKIT_TEST_EMPTY", "")
+
+	cases := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "plain text is unchanged",
+			input: "localhost",
+			want:  "localhost",
+		},
+		{
+			name:  "set variable",
+			input: "${DBKIT_TEST_HOST}:5432",
+			want:  "db.internal:5432",
+		},
+		{
+			name:  "set empty variable",
+			input: "[${DBKIT_TEST_EMPTY}]",
+			want:  "[]",
+		},
+		{
+			name:    "unset variable",
+			input:   "${DBKIT_TEST_UNSET}/${DBKIT_TEST_OTHER_UNSET}",
+			wantErr: "databases.main.config.host: missing environment variable(s): DBKIT_TEST_UNSET, DBKIT_TEST_OTHER_UNSET",
+		},
+		{
+			name:  "default is ignored for a set variable",
+			input: "${DBKIT_TEST_HOST:-localhost}",
+			want:  "db.internal",
+		},
+		{
+			name:  "default replaces an unset variable",
+			input: "${DBKIT_TEST_UNSET:-localhost}",
+			want:  "localhost",
+		},
+		{
+			name:  "default replaces an empty variable",
+			input: "${DBKIT_TEST_EMPTY:-localhost}",
+			want:  "localhost",
+		},
+		{
+			name:  "default may be empty",
+			input: "[${DBKIT_TEST_UNSET:-}]",
+			want:  "[]",
+		},
+		{
+			name:  "required variable that is set",
+			input: "${DBKIT_TEST_HOST:?host is required}",
+			want:  "db.internal",
+		},
+		{
+			name:    "required variable that is unset reports its key path and message",
+			input:   "${DBKIT_TEST_UNSET:?host is required}",
+			wantErr: "databases.main.config.host: DBKIT_TEST_UNSET host is required",
+		},
+		{
+			name:    "required variable that is empty uses the default message",
+			input:   "${DBKIT_TEST_EMPTY:?}",
+			wantErr: "databases.main.config.host: DBKIT_TEST_EMPTY is not set",
+		},
+		{
+			name:  "escaped dollar",
+			input: "pa$$word",
+			want:  "pa$word",
+		},
+		{
+			name:  "escaped variable reference",
+			input: "$${DBKIT_TEST_HOST}",
+			want:  "${DBKIT_TEST_HOST}",
+		},
+		{
+			name:  "escaped dollar before a variable",
+			input: "$$${DBKIT_TEST_HOST}",
+			want:  "$db.internal",
+		},
+		{
+			name:  "lone dollar is unchanged",
+			input: "$DBKIT_TEST_HOST and $",
+			want:  "$DBKIT_TEST_HOST and $",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			value := c.input
+			err := expandEnv("databases.main.config.host", &value)
+			if c.wantErr != "" {
+				if err == nil || err.Error() != c.wantErr {
+					t.Fatalf("expected error %q, got %v", c.wantErr, err)
+				}
+				if value != c.input {
+					t.Fatalf("expected value to be left unchanged, got %q", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to expand env: %v", err)
+			}
+			if value != c.want {
+				t.Fatalf("expected %q, got %q", c.want, value)
+			}
+		})
+	}
+}
+
+func TestExpandEnvConfig(t *testing.T) {
+	t.Run("reports the key path of an unset required variable", func(t *testing.T) {
+		config := &Config{
+			Databases: map[string]DriverConfig{
+				"main": {Driver: "pg", Config: map[string]string{"host": "localhost", "password": "${DBKIT_TEST_UNSET:?must be set}"}},
+			},
+		}
+		err := expandEnvConfig(config)
+		if err == nil || !strings.HasPrefix(err.Error(), "databases.main.config.password: DBKIT_TEST_UNSET must be set") {
+			t.Fatalf("expected error for databases.main.config.password, got %v", err)
+		}
+	})
+
+	t.Run("expands active, database and source values", func(t *testing.T) {
+		t.Setenv("DBKIT_TEST_DATABASE", "main")
+		config := &Config{
+			Active: ActiveConfig{
+				Source:   Source{Migrations: Migrations{Combined: "${DBKIT_TEST_SOURCE:-migrations}"}},
+				Database: "${DBKIT_TEST_DATABASE}",
+			},
+			Databases: map[string]DriverConfig{
+				"main": {Driver: "sqlite", Config: map[string]string{"path": "${DBKIT_TEST_UNSET:-./db.sqlite}"}},
+			},
+			Sources: map[string]DriverConfig{
+				"migrations": {Driver: "fs", Config: map[string]string{"dir": "./$${DBKIT_TEST_DATABASE}"}},
+			},
+		}
+		if err := expandEnvConfig(config); err != nil {
+			t.Fatalf("failed to expand config: %v", err)
+		}
+		if config.Active.Source.Migrations.Combined != "migrations" {
+			t.Fatalf("expected migrations source default, got %q", config.Active.Source.Migrations.Combined)
+		}
+		if config.Active.Database != "main" {
+			t.Fatalf("expected database main, got %q", config.Active.Database)
+		}
+		if path := config.Databases["main"].Config["path"]; path != "./db.sqlite" {
+			t.Fatalf("expected path default, got %q", path)
+		}
+		if dir := config.Sources["migrations"].Config["dir"]; dir != "./${DBKIT_TEST_DATABASE}" {
+			t.Fatalf("expected escaped dir, got %q", dir)
+		}
+	})
+}
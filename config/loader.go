@@ -1,49 +1,57 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-const FilePath = "dbkit.json"
+var FileNames = []string{"dbkit.json", "dbkit.yaml", "dbkit.yml", "dbkit.toml"}
 
-func LoadConfig() (config *Config, err error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, err
-	}
+func LoadConfig(overrides Overrides) (config *Config, err error) {
+	overrides = overrides.withEnv()
 
-	absCwdPath, err := filepath.Abs(cwd)
+	path, err := findConfigFile(overrides.ConfigPath)
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Open(filepath.Join(absCwdPath, FilePath))
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	config = &Config{
 		Global: GlobalConfig{
-			BaseDir: absCwdPath,
+			BaseDir: filepath.Dir(path),
 		},
 	}
 
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(config); err != nil {
-		return nil, err
+	if err := decodeConfig(path, data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
 	}
 
+	overrides.apply(config)
+
 	if err := config.validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %v", err)
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	if err := expandEnvEnvironments(config); err != nil {
+		return nil, err
 	}
 
 	if envPath, ok := config.Environments[config.Active.Environment]; ok {
+		if !filepath.IsAbs(envPath) {
+			envPath = filepath.Join(config.Global.BaseDir, envPath)
+		}
 		err := godotenv.Load(envPath)
 		if err != nil {
 			return nil, err
@@ -57,3 +65,71 @@ func LoadConfig() (config *Config, err error) {
 
 	return config, nil
 }
+
+func findConfigFile(explicitPath string) (string, error) {
+	if explicitPath != "" {
+		path, err := filepath.Abs(explicitPath)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("config file %s not found", explicitPath)
+		}
+		return path, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(cwd)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		var found []string
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				found = append(found, path)
+			}
+		}
+		if len(found) > 1 {
+			return "", fmt.Errorf("found multiple config files in %s (%s), keep one or pass --config", dir, strings.Join(found, ", "))
+		}
+		if len(found) == 1 {
+			return found[0], nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found in %s or any parent directory", strings.Join(FileNames, ", "), cwd)
+		}
+		dir = parent
+	}
+}
+
+func decodeConfig(path string, data []byte, config *Config) error {
+	var raw any
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		return decoder.Decode(config)
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	case ".toml":
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported config format '%s', expected .json, .yaml, .yml or .toml", ext)
+	}
+
+	converted, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, config)
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	cases := []struct {
+		name     string
+		files    map[string]string
+		cwd      string
+		explicit string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:  "finds a config file in the working directory",
+			files: map[string]string{"dbkit.json": "{}"},
+			want:  "dbkit.json",
+		},
+		{
+			name:  "finds a config file in a parent directory",
+			files: map[string]string{"dbkit.yaml": "", "a/b/.keep": ""},
+			cwd:   "a/b",
+			want:  "dbkit.yaml",
+		},
+		{
+			name:  "prefers the nearest config file",
+			files: map[string]string{"dbkit.json": "{}", "a/dbkit.toml": "", "a/b/.keep": ""},
+			cwd:   "a/b",
+			want:  "a/dbkit.toml",
+		},
+		{
+			name:    "rejects multiple config files in one directory",
+			files:   map[string]string{"dbkit.json": "{}", "dbkit.yml": "", "a/.keep": ""},
+			cwd:     "a",
+			wantErr: "found multiple config files in",
+		},
+		{
+			name:     "uses an explicit path without searching",
+			files:    map[string]string{"dbkit.json": "{}", "config/custom.yaml": ""},
+			explicit: "config/custom.yaml",
+			want:     "config/custom.yaml",
+		},
+		{
+			name:     "rejects a missing explicit path",
+			files:    map[string]string{"dbkit.json": "{}"},
+			explicit: "missing.json",
+			wantErr:  "config file missing.json not found",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to resolve temp dir: %v", err)
+			}
+			writeFiles(t, root, c.files)
+			t.Chdir(filepath.Join(root, c.cwd))
+			path, err := findConfigFile(c.explicit)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to find config file: %v", err)
+			}
+			if want := filepath.Join(root, c.want); path != want {
+				t.Fatalf("expected %s, got %s", want, path)
+			}
+		})
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	combined := Config{
+		Active: ActiveConfig{
+			Source:   Source{Migrations: Migrations{Combined: "migrations"}, Seeds: "seeds"},
+			Database: "sqlite",
+		},
+		Settings: Settings{AllowOutOfOrder: true, Timeout: "1m"},
+		Databases: map[string]DriverConfig{
+			"sqlite": {Driver: "sqlite", Config: map[string]string{"path": "./db.sqlite", "retries": "3", "ratio": "1.5", "wal": "true"}},
+		},
+	}
+	split := Config{
+		Active: ActiveConfig{
+			Source:   Source{Migrations: Migrations{Up: "up", Down: "down"}},
+			Database: "sqlite",
+		},
+		Settings: Settings{AllowOutOfOrder: false, LockTimeout: "30s"},
+		Databases: map[string]DriverConfig{
+			"sqlite": {Driver: "sqlite", Config: map[string]string{"path": "./db.sqlite"}},
+		},
+	}
+	cases := []struct {
+		name     string
+		file     string
+		contents string
+		want     Config
+		wantErr  string
+	}{
+		{
+			name: "json with a combined migration source",
+			file: "dbkit.json",
+			contents: `{
+				"active": {"source": {"migrations": "migrations", "seeds": "seeds"}, "database": "sqlite"},
+				"settings": {"allowOutOfOrder": true, "timeout": "1m"},
+				"databases": {"sqlite": {"driver": "sqlite", "config": {"path": "./db.sqlite", "retries": 3, "ratio": 1.5, "wal": true}}}
+			}`,
+			want: combined,
+		},
+		{
+			name: "yaml with a combined migration source",
+			file: "dbkit.yaml",
+			contents: `
+active:
+  source:
+    migrations: migrations
+    seeds: seeds
+  database: sqlite
+settings:
+  allowOutOfOrder: true
+  timeout: 1m
+databases:
+  sqlite:
+    driver: sqlite
+    config:
+      path: ./db.sqlite
+      retries: 3
+      ratio: 1.5
+      wal: true
+`,
+			want: combined,
+		},
+		{
+			name: "yaml with split migration sources",
+			file: "dbkit.yml",
+			contents: `
+active:
+  source:
+    migrations:
+      up: up
+      down: down
+  database: sqlite
+settings:
+  allowOutOfOrder: false
+  lockTimeout: 30s
+databases:
+  sqlite: {driver: sqlite, config: {path: ./db.sqlite}}
+`,
+			want: split,
+		},
+		{
+			name: "toml with a combined migration source",
+			file: "dbkit.toml",
+			contents: `
+[active]
+database = "sqlite"
+
+[active.source]
+migrations = "migrations"
+seeds = "seeds"
+
+[settings]
+allowOutOfOrder = true
+timeout = "1m"
+
+[databases.sqlite]
+driver = "sqlite"
+
+[databases.sqlite.config]
+path = "./db.sqlite"
+retries = 3
+ratio = 1.5
+wal = true
+`,
+			want: combined,
+		},
+		{
+			name: "toml with split migration sources",
+			file: "dbkit.toml",
+			contents: `
+[active]
+database = "sqlite"
+
+[active.source.migrations]
+up = "up"
+down = "down"
+
+[settings]
+allowOutOfOrder = false
+lockTimeout = "30s"
+
+[databases.sqlite]
+driver = "sqlite"
+config = { path = "./db.sqlite" }
+`,
+			want: split,
+		},
+		{
+			name:     "yaml with invalid migration sources",
+			file:     "dbkit.yaml",
+			contents: "active:\n  source:\n    migrations: [up, down]\n",
+			wantErr:  "active.source.migrations must be a source name or an object with up and down source names",
+		},
+		{
+			name:     "invalid toml",
+			file:     "dbkit.toml",
+			contents: "[active\n",
+			wantErr:  "toml",
+		},
+		{
+			name:     "unsupported format",
+			file:     "dbkit.ini",
+			contents: "",
+			wantErr:  "unsupported config format '.ini'",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var config Config
+			err := decodeConfig(c.file, []byte(c.contents), &config)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to decode config: %v", err)
+			}
+			if config.Active != c.want.Active {
+				t.Fatalf("expected active %+v, got %+v", c.want.Active, config.Active)
+			}
+			if config.Settings != c.want.Settings {
+				t.Fatalf("expected settings %+v, got %+v", c.want.Settings, config.Settings)
+			}
+			for name, want := range c.want.Databases {
+				got := config.Databases[name]
+				if got.Driver != want.Driver || !maps.Equal(got.Config, want.Config) {
+					t.Fatalf("expected database %s to be %+v, got %+v", name, want, got)
+				}
+			}
+		})
+	}
+}
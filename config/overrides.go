@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+type Overrides struct {
+	ConfigPath  string
+	Database    string
+	Environment string
+	Source      string
+}
+
+func (o Overrides) withEnv() Overrides {
+	if o.ConfigPath == "" {
+		o.ConfigPath = os.Getenv("DBKIT_CONFIG")
+	}
+	if o.Database == "" {
+		o.Database = os.Getenv("DBKIT_DATABASE")
+	}
+	if o.Environment == "" {
+		o.Environment = os.Getenv("DBKIT_ENV")
+	}
+	if o.Source == "" {
+		o.Source = os.Getenv("DBKIT_SOURCE")
+	}
+	return o
+}
+
+func (o Overrides) apply(c *Config) {
+	if o.Database != "" {
+		c.Active.Database = o.Database
+	}
+	if o.Environment != "" {
+		c.Active.Environment = o.Environment
+	}
+	if o.Source != "" {
+		if up, down, split := strings.Cut(o.Source, ","); split {
+			c.Active.Source.Migrations = Migrations{Up: up, Down: down}
+		} else {
+			c.Active.Source.Migrations = Migrations{Combined: o.Source}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestOverridesWithEnv(t *testing.T) {
+	cases := []struct {
+		name      string
+		overrides Overrides
+		env       map[string]string
+		want      Overrides
+	}{
+		{
+			name: "environment fills unset overrides",
+			env: map[string]string{
+				"DBKIT_CONFIG":   "env.json",
+				"DBKIT_DATABASE": "envdb",
+				"DBKIT_ENV":      "envenv",
+				"DBKIT_SOURCE":   "envsource",
+			},
+			want: Overrides{ConfigPath: "env.json", Database: "envdb", Environment: "envenv", Source: "envsource"},
+		},
+		{
+			name:      "flags take precedence over the environment",
+			overrides: Overrides{ConfigPath: "flag.json", Database: "flagdb", Environment: "flagenv", Source: "flagsource"},
+			env: map[string]string{
+				"DBKIT_CONFIG":   "env.json",
+				"DBKIT_DATABASE": "envdb",
+				"DBKIT_ENV":      "envenv",
+				"DBKIT_SOURCE":   "envsource",
+			},
+			want: Overrides{ConfigPath: "flag.json", Database: "flagdb", Environment: "flagenv", Source: "flagsource"},
+		},
+		{
+			name:      "flags and the environment combine",
+			overrides: Overrides{Database: "flagdb"},
+			env: map[string]string{
+				"DBKIT_DATABASE": "envdb",
+				"DBKIT_SOURCE":   "up,down",
+			},
+			want: Overrides{Database: "flagdb", Source: "up,down"},
+		},
+		{
+			name: "nothing set leaves overrides empty",
+			want: Overrides{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, key := range []string{"DBKIT_CONFIG", "DBKIT_DATABASE", "DBKIT_ENV", "DBKIT_SOURCE"} {
+				t.Setenv(key, c.env[key])
+			}
+			if got := c.overrides.withEnv(); got != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestOverridesApply(t *testing.T) {
+	cases := []struct {
+		name      string
+		overrides Overrides
+		want      ActiveConfig
+	}{
+		{
+			name: "no overrides keep the active config",
+			want: ActiveConfig{
+				Source:      Source{Migrations: Migrations{Up: "up", Down: "down"}, Seeds: "seeds"},
+				Database:    "main",
+				Environment: "dev",
+			},
+		},
+		{
+			name:      "database and environment replace the active ones",
+			overrides: Overrides{Database: "other", Environment: "prod"},
+			want: ActiveConfig{
+				Source:      Source{Migrations: Migrations{Up: "up", Down: "down"}, Seeds: "seeds"},
+				Database:    "other",
+				Environment: "prod",
+			},
+		},
+		{
+			name:      "a source name selects a combined migration source",
+			overrides: Overrides{Source: "combined"},
+			want: ActiveConfig{
+				Source:      Source{Migrations: Migrations{Combined: "combined"}, Seeds: "seeds"},
+				Database:    "main",
+				Environment: "dev",
+			},
+		},
+		{
+			name:      "an up,down pair selects split migration sources",
+			overrides: Overrides{Source: "up2,down2"},
+			want: ActiveConfig{
+				Source:      Source{Migrations: Migrations{Up: "up2", Down: "down2"}, Seeds: "seeds"},
+				Database:    "main",
+				Environment: "dev",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{
+				Active: ActiveConfig{
+					Source:      Source{Migrations: Migrations{Up: "up", Down: "down"}, Seeds: "seeds"},
+					Database:    "main",
+					Environment: "dev",
+				},
+			}
+			c.overrides.apply(config)
+			if config.Active != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, config.Active)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+func appliedMigrationStore(t *testing.T, conn db.Connection) db.AppliedMigrationStore {
+	t.Helper()
+	store := conn.AppliedMigrationStore()
+	if err := store.EnsureSchema(t.Context()); err != nil {
+		t.Fatalf("failed to ensure schema: %v", err)
+	}
+	return store
+}
+
+func recordStarted(t *testing.T, store db.AppliedMigrationStore) string {
+	t.Helper()
+	return recordStartedWithInfo(t, store, db.RunInfo{})
+}
+
+func recordStartedWithInfo(t *testing.T, store db.AppliedMigrationStore, info db.RunInfo) string {
+	t.Helper()
+	id := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := store.RecordStarted(t.Context(), id, "checksum", info); err != nil {
+		t.Fatalf("failed to record migration start: %v", err)
+	}
+	t.Cleanup(func() { store.Remove(context.Background(), id) })
+	return id
+}
+
+func containsId(migrations []db.AppliedMigration, id string) bool {
+	return slices.ContainsFunc(migrations, func(migration db.AppliedMigration) bool {
+		return migration.Id == id
+	})
+}
+
+func findApplied(t *testing.T, store db.AppliedMigrationStore, id string) db.AppliedMigration {
+	t.Helper()
+	applied, err := store.List(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list migrations: %v", err)
+	}
+	index := slices.IndexFunc(applied, func(migration db.AppliedMigration) bool {
+		return migration.Id == id
+	})
+	if index == -1 {
+		t.Fatalf("expected %s to be listed", id)
+	}
+	return applied[index]
+}
+
+func listDirty(t *testing.T, store db.AppliedMigrationStore) []db.AppliedMigration {
+	t.Helper()
+	dirty, err := store.ListDirty(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list dirty migrations: %v", err)
+	}
+	return dirty
+}
+
+func TestAppliedMigrationStore(t *testing.T) {
+	driverCases := getDriverCases()
+	for _, driverCase := range driverCases {
+		t.Run(driverCase.name(), func(t *testing.T) {
+
+			t.Run("schema exists after it has been ensured", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				exists, err := store.SchemaExists(t.Context())
+				if err != nil {
+					t.Fatalf("failed to check schema: %v", err)
+				}
+				if !exists {
+					t.Fatalf("expected schema to exist")
+				}
+			})
+
+			t.Run("schema is at the latest version after it has been ensured", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				current, latest, err := store.SchemaVersion(t.Context())
+				if err != nil {
+					t.Fatalf("failed to read schema version: %v", err)
+				}
+				if latest < 1 || current != latest {
+					t.Fatalf("expected schema version %d to be the latest version %d", current, latest)
+				}
+			})
+
+			t.Run("ensuring the schema again is a no-op", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.EnsureSchema(t.Context()); err != nil {
+					t.Fatalf("failed to ensure schema again: %v", err)
+				}
+				findApplied(t, store, id)
+			})
+
+			t.Run("unfinished migrations are listed as dirty", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if !containsId(listDirty(t, store), id) {
+					t.Fatalf("expected %s to be listed as dirty", id)
+				}
+			})
+
+			t.Run("finished migrations are not listed as dirty", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.RecordFinished(ctx, id, time.Second); err != nil {
+					t.Fatalf("failed to record migration finish: %v", err)
+				}
+				if containsId(listDirty(t, store), id) {
+					t.Fatalf("expected %s not to be listed as dirty", id)
+				}
+			})
+
+			t.Run("started rollbacks are listed as dirty", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.RecordFinished(ctx, id, time.Second); err != nil {
+					t.Fatalf("failed to record migration finish: %v", err)
+				}
+				if err := store.RecordRollbackStarted(ctx, id); err != nil {
+					t.Fatalf("failed to record rollback start: %v", err)
+				}
+				if !containsId(listDirty(t, store), id) {
+					t.Fatalf("expected %s to be listed as dirty", id)
+				}
+			})
+
+			t.Run("marking a dirty migration as applied cleans it", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.RecordRollbackStarted(ctx, id); err != nil {
+					t.Fatalf("failed to record rollback start: %v", err)
+				}
+				if err := store.MarkApplied(ctx, id); err != nil {
+					t.Fatalf("failed to mark migration as applied: %v", err)
+				}
+				if containsId(listDirty(t, store), id) {
+					t.Fatalf("expected %s not to be listed as dirty", id)
+				}
+			})
+
+			t.Run("marking an unknown migration as applied fails", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				if err := store.MarkApplied(t.Context(), "unknown_migration"); err == nil {
+					t.Fatalf("expected error when marking an unknown migration as applied")
+				}
+			})
+
+			t.Run("removed migrations are no longer listed", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.Remove(ctx, id); err != nil {
+					t.Fatalf("failed to remove migration: %v", err)
+				}
+				applied, err := store.List(ctx)
+				if err != nil {
+					t.Fatalf("failed to list migrations: %v", err)
+				}
+				if containsId(applied, id) {
+					t.Fatalf("expected %s to be removed", id)
+				}
+			})
+
+			t.Run("run info is recorded when a migration starts", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				info := db.RunInfo{
+					AppliedBy: "user",
+					Host:      "host",
+					Version:   "v1.2.3",
+					Note:      "note",
+				}
+				id := recordStartedWithInfo(t, store, info)
+				applied := findApplied(t, store, id)
+				if applied.RunInfo != info {
+					t.Fatalf("expected run info %+v, got %+v", info, applied.RunInfo)
+				}
+				if applied.Duration != nil {
+					t.Fatalf("expected unfinished migration to have no duration")
+				}
+			})
+
+			t.Run("duration is recorded when a migration finishes", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := recordStarted(t, store)
+				if err := store.RecordFinished(t.Context(), id, 1500*time.Millisecond); err != nil {
+					t.Fatalf("failed to record migration finish: %v", err)
+				}
+				applied := findApplied(t, store, id)
+				if applied.Duration == nil || *applied.Duration != 1500*time.Millisecond {
+					t.Fatalf("expected duration of 1.5s, got %v", applied.Duration)
+				}
+			})
+
+			t.Run("history entries are listed in the order they were recorded", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				store := appliedMigrationStore(t, conn)
+				id := fmt.Sprintf("test_%d", time.Now().UnixNano())
+				for _, action := range []string{"up", "down"} {
+					err := store.RecordHistory(ctx, db.HistoryEntry{
+						MigrationId: id,
+						Action:      action,
+						Checksum:    "checksum",
+						StartedAt:   time.Now(),
+						FinishedAt:  time.Now(),
+						Duration:    time.Second,
+						RunInfo:     db.RunInfo{Note: action},
+					})
+					if err != nil {
+						t.Fatalf("failed to record history: %v", err)
+					}
+				}
+				history, err := store.History(ctx)
+				if err != nil {
+					t.Fatalf("failed to list history: %v", err)
+				}
+				var actions []string
+				for _, entry := range history {
+					if entry.MigrationId == id {
+						actions = append(actions, entry.Action)
+						if entry.Note != entry.Action || entry.Duration != time.Second {
+							t.Fatalf("unexpected history entry: %+v", entry)
+						}
+					}
+				}
+				if !slices.Equal(actions, []string{"up", "down"}) {
+					t.Fatalf("expected history actions [up down], got %v", actions)
+				}
+			})
+
+		})
+	}
+}
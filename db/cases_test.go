@@ -1,19 +1,51 @@
 package db_test
 
 import (
+	"maps"
+	"net"
+	"strconv"
+	"sync"
+
 	"github.com/easynow112/dbkit/config"
 	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/db/mysql"
 	"github.com/easynow112/dbkit/db/pg"
 	"github.com/easynow112/dbkit/db/test"
+
+	gms "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
 )
 
 type driverCase struct {
-	factory db.DriverFactory
-	config  config.DriverConfig
+	factory    db.DriverFactory
+	config     config.DriverConfig
+	sleepQuery string
+	// closesOnCancel is set when the driver discards a connection whose
+	// statement was interrupted by its context
+	closesOnCancel bool
+	// tables lists the qualified dbkit tables a case with a custom 'table'
+	// or 'schema' must create instead of the default ones
+	tables []string
+}
+
+func (c driverCase) name() string {
+	if table, ok := c.config.Config["table"]; ok {
+		return c.config.Driver + "_" + table
+	}
+	return c.config.Driver
+}
+
+func withTables(c driverCase, settings map[string]string, tables ...string) driverCase {
+	c.config.Config = maps.Clone(c.config.Config)
+	maps.Copy(c.config.Config, settings)
+	c.tables = tables
+	return c
 }
 
 func getDriverCases() []driverCase {
-	return []driverCase{
+	cases := []driverCase{
 		{
 			factory: pg.NewDB,
 			config: config.DriverConfig{
@@ -27,12 +59,58 @@ func getDriverCases() []driverCase {
 					"ssl":      "prefer",
 				},
 			},
+			sleepQuery: "SELECT pg_sleep(10)",
+		},
+		{
+			factory: mysql.NewDB,
+			config: config.DriverConfig{
+				Driver: "mysql",
+				Config: map[string]string{
+					"host":     "127.0.0.1",
+					"port":     strconv.Itoa(mysqlServerPort()),
+					"user":     "root",
+					"password": "",
+					"name":     "database",
+				},
+			},
+			sleepQuery:     "SELECT SLEEP(10)",
+			closesOnCancel: true,
 		},
 		{
 			factory: test.NewFactory(test.NewStore(map[string]*db.AppliedMigration{})),
 			config: config.DriverConfig{
 				Driver: "test",
 			},
+			sleepQuery: "SLEEP",
 		},
 	}
+	return append(cases,
+		withTables(cases[0], map[string]string{"table": "dbkit_custom", "schema": "dbkit_custom_schema"},
+			`"dbkit_custom_schema"."dbkit_custom"`,
+			`"dbkit_custom_schema"."dbkit_custom_history"`,
+			`"dbkit_custom_schema"."dbkit_custom_meta"`,
+			`"dbkit_custom_schema"."dbkit_custom_lock"`,
+		),
+		withTables(cases[1], map[string]string{"table": "dbkit_custom"},
+			"`dbkit_custom`",
+			"`dbkit_custom_history`",
+			"`dbkit_custom_meta`",
+			"`dbkit_custom_lock`",
+		),
+	)
 }
+
+var mysqlServerPort = sync.OnceValue(func() int {
+	provider := memory.NewDBProvider(memory.NewDatabase("database"))
+	engine := gms.NewDefault(provider)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	srv, err := server.NewServer(server.Config{Protocol: "tcp", Listener: listener}, engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		panic(err)
+	}
+	go srv.Start()
+	return listener.Addr().(*net.TCPAddr).Port
+})
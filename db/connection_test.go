@@ -42,7 +42,7 @@ func acquireLock(t *testing.T, conn db.Connection) db.Lock {
 func TestConnection(t *testing.T) {
 	driverCases := getDriverCases()
 	for _, driverCase := range driverCases {
-		t.Run(driverCase.config.Driver, func(t *testing.T) {
+		t.Run(driverCase.name(), func(t *testing.T) {
 
 			t.Run("closing an open connection succeeds", func(t *testing.T) {
 				t.Parallel()
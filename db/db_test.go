@@ -27,7 +27,7 @@ func initDB(t *testing.T, driverCase driverCase) db.DB {
 func TestDB(t *testing.T) {
 	driverCases := getDriverCases()
 	for _, driverCase := range driverCases {
-		t.Run(driverCase.config.Driver, func(t *testing.T) {
+		t.Run(driverCase.name(), func(t *testing.T) {
 			t.Parallel()
 
 			t.Run("acquiring and closing connection succeeds", func(t *testing.T) {
@@ -12,6 +12,9 @@ type DB interface {
 
 type Connection interface {
 	TryAcquireLock(ctx context.Context) (Lock, error)
+	AcquireLock(ctx context.Context, timeout time.Duration) (Lock, error)
+	LockStatus(ctx context.Context) (LockStatus, error)
+	ForceReleaseLock(ctx context.Context, terminate bool) (LockStatus, error)
 	Close() error
 	AppliedMigrationStore() AppliedMigrationStore
 	Exec(ctx context.Context, query string, args ...any) error
@@ -22,19 +25,59 @@ type Transaction interface {
 	Commit(ctx context.Context) error
 	Rollback(ctx context.Context) error
 	Exec(ctx context.Context, query string, args ...any) error
+	AppliedMigrationStore() AppliedMigrationStore
+}
+
+type StatementTimeoutSetter interface {
+	SetStatementTimeout(ctx context.Context, timeout time.Duration) error
+}
+
+// DDLCommitter is implemented by connections whose DDL statements implicitly
+// commit the open transaction, so a failed migration can leave its earlier
+// statements applied.
+type DDLCommitter interface {
+	CommitsDDL() bool
 }
 
 type Lock interface {
 	Release(ctx context.Context) error
+	Lost() <-chan struct{}
+}
+
+type LockOwner struct {
+	User string
+	Host string
+	Pid  int
+}
+
+type LockStatus struct {
+	Held       bool
+	Owner      LockOwner
+	Since      *time.Time
+	Expires    *time.Time
+	BackendPid int
 }
 
 type AppliedMigrationStore interface {
 	EnsureSchema(ctx context.Context) error
+	SchemaExists(ctx context.Context) (bool, error)
+	SchemaVersion(ctx context.Context) (current int, latest int, err error)
 	List(ctx context.Context) ([]AppliedMigration, error)
+	ListDirty(ctx context.Context) ([]AppliedMigration, error)
 	Remove(ctx context.Context, id string) error
-	RecordStarted(ctx context.Context, id string, checksum string) error
-	RecordFinished(ctx context.Context, id string) error
+	MarkApplied(ctx context.Context, id string) error
+	RecordStarted(ctx context.Context, id string, checksum string, info RunInfo) error
+	RecordFinished(ctx context.Context, id string, duration time.Duration) error
 	RecordRollbackStarted(ctx context.Context, id string) error
+	RecordHistory(ctx context.Context, entry HistoryEntry) error
+	History(ctx context.Context) ([]HistoryEntry, error)
+}
+
+type RunInfo struct {
+	AppliedBy string
+	Host      string
+	Version   string
+	Note      string
 }
 
 type AppliedMigration struct {
@@ -43,4 +86,16 @@ type AppliedMigration struct {
 	StartedAt         time.Time
 	FinishedAt        *time.Time
 	RollbackStartedAt *time.Time
+	Duration          *time.Duration
+	RunInfo
+}
+
+type HistoryEntry struct {
+	MigrationId string
+	Action      string
+	Checksum    string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Duration    time.Duration
+	RunInfo
 }
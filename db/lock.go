@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+var (
+	ErrLockHeld = errors.New("another migration process is already running")
+	ErrLockLost = errors.New("migration lock was lost to another process")
+
+	ErrTerminateRequired = errors.New("the lock is held by another database session and can only be released by terminating it")
+)
+
+const (
+	lockPollInitial = 50 * time.Millisecond
+	lockPollMax     = 2 * time.Second
+)
+
+func WaitForLock(ctx context.Context, timeout time.Duration, tryAcquire func(ctx context.Context) (Lock, error)) (Lock, error) {
+	deadline := time.Now().Add(timeout)
+	backoff := lockPollInitial
+	for {
+		lock, err := tryAcquire(ctx)
+		if !errors.Is(err, ErrLockHeld) {
+			return lock, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("timed out after %s waiting for lock: %w", timeout, err)
+		}
+		timer := time.NewTimer(min(backoff, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, lockPollMax)
+	}
+}
+
+func CurrentLockOwner() LockOwner {
+	owner := LockOwner{
+		User: os.Getenv("USER"),
+		Pid:  os.Getpid(),
+	}
+	if current, err := user.Current(); err == nil {
+		owner.User = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		owner.Host = host
+	}
+	return owner
+}
+
+func WaitForRelease(ctx context.Context, conn Connection) error {
+	for range 50 {
+		status, err := conn.LockStatus(ctx)
+		if err != nil || !status.Held {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("lock is still held after terminating its holder")
+}
@@ -2,16 +2,22 @@ package db_test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/easynow112/dbkit/config"
 	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/db/sqlite"
 )
 
 func TestLock(t *testing.T) {
 	driverCases := getDriverCases()
 	for _, driverCase := range driverCases {
-		t.Run(driverCase.config.Driver, func(t *testing.T) {
+		t.Run(driverCase.name(), func(t *testing.T) {
 
 			t.Run("locks are exclusive within a connection pool", func(t *testing.T) {
 				ctx := t.Context()
@@ -113,6 +119,301 @@ func TestLock(t *testing.T) {
 				}
 			})
 
+			t.Run("attempting to acquire a held lock reports ErrLockHeld", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool)
+				conn2 := acquireConnection(t, pool)
+				acquireLock(t, conn1)
+				lock2, err := conn2.TryAcquireLock(ctx)
+				if err == nil {
+					t.Cleanup(func() { lock2.Release(context.Background()) })
+				}
+				if !errors.Is(err, db.ErrLockHeld) {
+					t.Fatalf("expected ErrLockHeld, got: %v", err)
+				}
+			})
+
+			t.Run("waiting for a lock succeeds once it is released", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool)
+				conn2 := acquireConnection(t, pool)
+				lock1, err := conn1.TryAcquireLock(ctx)
+				if err != nil {
+					t.Fatalf("failed to acquire lock: %v", err)
+				}
+				go func() {
+					time.Sleep(200 * time.Millisecond)
+					lock1.Release(context.Background())
+				}()
+				lock2, err := conn2.AcquireLock(ctx, 5*time.Second)
+				if err != nil {
+					t.Fatalf("expected to acquire lock after it was released: %v", err)
+				}
+				t.Cleanup(func() { lock2.Release(context.Background()) })
+			})
+
+			t.Run("waiting for a held lock times out", func(t *testing.T) {
+				ctx := t.Context()
+				pool := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool)
+				conn2 := acquireConnection(t, pool)
+				acquireLock(t, conn1)
+				startedAt := time.Now()
+				lock2, err := conn2.AcquireLock(ctx, 300*time.Millisecond)
+				if err == nil {
+					t.Cleanup(func() { lock2.Release(context.Background()) })
+				}
+				if !errors.Is(err, db.ErrLockHeld) {
+					t.Fatalf("expected ErrLockHeld after timing out, got: %v", err)
+				}
+				if waited := time.Since(startedAt); waited < 300*time.Millisecond {
+					t.Fatalf("expected to wait for the timeout, waited %s", waited)
+				}
+			})
+
+			t.Run("a held lock is not reported as lost", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				lock := acquireLock(t, conn)
+				select {
+				case <-lock.Lost():
+					t.Fatalf("expected held lock not to be reported as lost")
+				case <-time.After(100 * time.Millisecond):
+				}
+			})
+
+			t.Run("lock status reports a free lock", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				status, err := conn.LockStatus(t.Context())
+				if err != nil {
+					t.Fatalf("failed to get lock status: %v", err)
+				}
+				if status.Held {
+					t.Fatalf("expected lock not to be held, got %+v", status)
+				}
+			})
+
+			t.Run("lock status reports the owner of a held lock", func(t *testing.T) {
+				pool1 := initDB(t, driverCase)
+				pool2 := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool1)
+				conn2 := acquireConnection(t, pool2)
+				before := time.Now().Add(-time.Second)
+				acquireLock(t, conn1)
+				status, err := conn2.LockStatus(t.Context())
+				if err != nil {
+					t.Fatalf("failed to get lock status: %v", err)
+				}
+				if !status.Held {
+					t.Fatalf("expected lock to be held")
+				}
+				if status.Owner != db.CurrentLockOwner() {
+					t.Fatalf("expected owner %+v, got %+v", db.CurrentLockOwner(), status.Owner)
+				}
+				if status.Since == nil || status.Since.Before(before) {
+					t.Fatalf("expected lock to be held since after %v, got %v", before, status.Since)
+				}
+			})
+
+			t.Run("force release frees a lock held by another connection pool", func(t *testing.T) {
+				ctx := t.Context()
+				pool1 := initDB(t, driverCase)
+				pool2 := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool1)
+				conn2 := acquireConnection(t, pool2)
+				if _, err := conn1.TryAcquireLock(ctx); err != nil {
+					t.Fatalf("failed to acquire lock: %v", err)
+				}
+				released, err := conn2.ForceReleaseLock(ctx, true)
+				if err != nil {
+					t.Fatalf("failed to force release lock: %v", err)
+				}
+				if !released.Held {
+					t.Fatalf("expected released status to describe the held lock")
+				}
+				status, err := conn2.LockStatus(ctx)
+				if err != nil {
+					t.Fatalf("failed to get lock status: %v", err)
+				}
+				if status.Held {
+					t.Fatalf("expected lock to be free after force release, got %+v", status)
+				}
+				acquireLock(t, conn2)
+			})
+
+			t.Run("waiting for a lock respects cancelled context", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn1 := acquireConnection(t, pool)
+				conn2 := acquireConnection(t, pool)
+				acquireLock(t, conn1)
+				ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+				defer cancel()
+				lock2, err := conn2.AcquireLock(ctx, time.Minute)
+				if err == nil {
+					t.Cleanup(func() { lock2.Release(context.Background()) })
+					t.Fatalf("expected waiting to stop when the context is cancelled")
+				}
+			})
+
 		})
 	}
 }
+
+func sqliteLockCase(t *testing.T) driverCase {
+	return driverCase{
+		factory: sqlite.NewDB,
+		config: config.DriverConfig{
+			Driver: "sqlite",
+			Config: map[string]string{
+				"path":          filepath.Join(t.TempDir(), "lock.sqlite"),
+				"lockExpiry":    "1s",
+				"lockHeartbeat": "200ms",
+			},
+		},
+	}
+}
+
+func TestSQLiteLock(t *testing.T) {
+	t.Run("renewal is not rolled back with a transaction on the lock connection", func(t *testing.T) {
+		ctx := t.Context()
+		driverCase := sqliteLockCase(t)
+		pool1 := initDB(t, driverCase)
+		pool2 := initDB(t, driverCase)
+		conn1 := acquireConnection(t, pool1)
+		conn2 := acquireConnection(t, pool2)
+		lock := acquireLock(t, conn1)
+
+		trx := beginTrx(t, conn1)
+		if err := trx.Exec(ctx, "SELECT 1"); err != nil {
+			t.Fatalf("failed to exec in transaction: %v", err)
+		}
+		time.Sleep(1500 * time.Millisecond)
+		if err := trx.Rollback(ctx); err != nil {
+			t.Fatalf("failed to rollback transaction: %v", err)
+		}
+
+		lock2, err := conn2.TryAcquireLock(ctx)
+		if err == nil {
+			t.Cleanup(func() { lock2.Release(context.Background()) })
+			t.Fatalf("expected lock to still be held after a transaction longer than its expiry")
+		}
+		if !errors.Is(err, db.ErrLockHeld) {
+			t.Fatalf("expected ErrLockHeld, got %v", err)
+		}
+		select {
+		case <-lock.Lost():
+			t.Fatalf("expected lock not to be lost")
+		default:
+		}
+	})
+
+	t.Run("lock is lost when a migration blocks renewal until its expiry", func(t *testing.T) {
+		ctx := t.Context()
+		driverCase := sqliteLockCase(t)
+		pool := initDB(t, driverCase)
+		conn := acquireConnection(t, pool)
+		lock := acquireLock(t, conn)
+
+		trx := beginTrx(t, conn)
+		defer trx.Rollback(ctx)
+		if err := trx.Exec(ctx, "CREATE TABLE migrating (id INTEGER)"); err != nil {
+			t.Fatalf("failed to exec in transaction: %v", err)
+		}
+		select {
+		case <-lock.Lost():
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expected lock to be lost while renewal was blocked")
+		}
+	})
+
+	t.Run("lock status reads a legacy lock table while a migration holds the write lock", func(t *testing.T) {
+		ctx := t.Context()
+		driverCase := sqliteLockCase(t)
+		pool1 := initDB(t, driverCase)
+		pool2 := initDB(t, driverCase)
+		conn1 := acquireConnection(t, pool1)
+		conn2 := acquireConnection(t, pool2)
+		statements := []string{
+			"CREATE TABLE migration_lock (id INTEGER PRIMARY KEY CHECK (id = 0), locked BOOLEAN NOT NULL, owner INTEGER, lock_expires INTEGER)",
+			"INSERT INTO migration_lock (id, locked, owner, lock_expires) VALUES (0, 1, 1, unixepoch() + 60)",
+		}
+		for _, statement := range statements {
+			if err := conn1.Exec(ctx, statement); err != nil {
+				t.Fatalf("failed to create legacy lock table: %v", err)
+			}
+		}
+		trx := beginTrx(t, conn1)
+		defer trx.Rollback(ctx)
+		if err := trx.Exec(ctx, "CREATE TABLE migrating (id INTEGER)"); err != nil {
+			t.Fatalf("failed to exec in transaction: %v", err)
+		}
+		status, err := conn2.LockStatus(ctx)
+		if err != nil {
+			t.Fatalf("failed to get lock status: %v", err)
+		}
+		if !status.Held {
+			t.Fatalf("expected lock to be held, got %+v", status)
+		}
+		if status.Owner != (db.LockOwner{}) {
+			t.Fatalf("expected no owner details, got %+v", status.Owner)
+		}
+	})
+	t.Run("lock acquisition upgrades a legacy lock table once through the versioned schema", func(t *testing.T) {
+		ctx := t.Context()
+		driverCase := sqliteLockCase(t)
+		pool := initDB(t, driverCase)
+		conn := acquireConnection(t, pool)
+		statements := []string{
+			"CREATE TABLE migration_lock (id INTEGER PRIMARY KEY CHECK (id = 0), locked BOOLEAN NOT NULL, owner INTEGER, lock_expires INTEGER)",
+			"INSERT INTO migration_lock (id, locked, owner, lock_expires) VALUES (0, 0, NULL, NULL)",
+		}
+		for _, statement := range statements {
+			if err := conn.Exec(ctx, statement); err != nil {
+				t.Fatalf("failed to create legacy lock table: %v", err)
+			}
+		}
+
+		lock, err := conn.TryAcquireLock(ctx)
+		if err != nil {
+			t.Fatalf("failed to acquire lock: %v", err)
+		}
+		status, err := conn.LockStatus(ctx)
+		if err != nil {
+			t.Fatalf("failed to get lock status: %v", err)
+		}
+		if status.Owner != db.CurrentLockOwner() {
+			t.Fatalf("expected owner %+v to be recorded, got %+v", db.CurrentLockOwner(), status.Owner)
+		}
+		current, latest, err := conn.AppliedMigrationStore().SchemaVersion(ctx)
+		if err != nil {
+			t.Fatalf("failed to get schema version: %v", err)
+		}
+		if current != latest {
+			t.Fatalf("expected schema version %d, got %d", latest, current)
+		}
+		if err := lock.Release(ctx); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+
+		sqlDB, err := sql.Open("sqlite", "file:"+driverCase.config.Config["path"])
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		defer sqlDB.Close()
+		schemaCookie := func() int {
+			var cookie int
+			if err := sqlDB.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&cookie); err != nil {
+				t.Fatalf("failed to read schema cookie: %v", err)
+			}
+			return cookie
+		}
+		before := schemaCookie()
+		acquireLock(t, conn)
+		if after := schemaCookie(); after != before {
+			t.Fatalf("expected lock acquisition on an up to date schema to run no DDL, schema cookie changed from %d to %d", before, after)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type AppliedMigrationStore struct {
+	querier querier
+	tables  *tables
+}
+
+func (store *AppliedMigrationStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return store.querier.ExecContext(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return store.querier.QueryContext(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return store.querier.QueryRowContext(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) EnsureSchema(ctx context.Context) error {
+	return store.upgradeSchema(ctx)
+}
+
+func (store *AppliedMigrationStore) SchemaVersion(ctx context.Context) (current int, latest int, err error) {
+	current, err = store.schemaVersion(ctx)
+	return current, len(schemaUpgrades), err
+}
+
+func (store *AppliedMigrationStore) SchemaExists(ctx context.Context) (bool, error) {
+	return store.tableExists(ctx, store.tables.Migrations)
+}
+
+func (store *AppliedMigrationStore) tableExists(ctx context.Context, table string) (bool, error) {
+	var count int
+	err := store.querier.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = ?
+	`, table).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+const (
+	selectColumns       = `SELECT id, checksum, started_at, finished_at, rollback_started_at, duration_ms, COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '') FROM {migrations}`
+	selectLegacyColumns = `SELECT id, checksum, started_at, finished_at, rollback_started_at, NULL, '', '', '', '' FROM {migrations}`
+)
+
+func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigration, error) {
+	return store.list(ctx, ` ORDER BY started_at ASC, id ASC`)
+}
+
+func (store *AppliedMigrationStore) ListDirty(ctx context.Context) ([]db.AppliedMigration, error) {
+	return store.list(ctx, ` WHERE finished_at IS NULL OR rollback_started_at IS NOT NULL ORDER BY started_at ASC, id ASC`)
+}
+
+func (store *AppliedMigrationStore) list(ctx context.Context, clauses string) ([]db.AppliedMigration, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := selectColumns + clauses
+	if version < schemaVersionRunInfo {
+		query = selectLegacyColumns + clauses
+	}
+	rows, err := store.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]db.AppliedMigration, 0)
+	for rows.Next() {
+		var row db.AppliedMigration
+		var checksum sql.NullString
+		var durationMs *int64
+		err = rows.Scan(
+			&row.Id,
+			&checksum,
+			&row.StartedAt,
+			&row.FinishedAt,
+			&row.RollbackStartedAt,
+			&durationMs,
+			&row.AppliedBy,
+			&row.Host,
+			&row.Version,
+			&row.Note,
+		)
+		if err != nil {
+			return nil, err
+		}
+		row.Checksum = checksum.String
+		if durationMs != nil {
+			duration := time.Duration(*durationMs) * time.Millisecond
+			row.Duration = &duration
+		}
+		results = append(results, row)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error {
+	res, err := store.exec(ctx, `DELETE FROM {migrations} WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (store *AppliedMigrationStore) MarkApplied(ctx context.Context, id string) error {
+	res, err := store.exec(ctx, `UPDATE {migrations} SET finished_at = COALESCE(finished_at, UTC_TIMESTAMP(6)), rollback_started_at = NULL WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string, info db.RunInfo) error {
+	res, err := store.exec(ctx, `INSERT INTO {migrations} (id, checksum, started_at, applied_by, host, dbkit_version, note) VALUES (?, ?, UTC_TIMESTAMP(6), ?, ?, ?, ?)`, id, checksum, info.AppliedBy, info.Host, info.Version, info.Note)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string, duration time.Duration) error {
+	res, err := store.exec(ctx, `UPDATE {migrations} SET finished_at = UTC_TIMESTAMP(6), duration_ms = ? WHERE id = ?`, duration.Milliseconds(), id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, id string) error {
+	res, err := store.exec(ctx, `UPDATE {migrations} SET rollback_started_at = UTC_TIMESTAMP(6) WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (store *AppliedMigrationStore) RecordHistory(ctx context.Context, entry db.HistoryEntry) error {
+	_, err := store.exec(ctx, `
+		INSERT INTO {history} (migration_id, action, checksum, started_at, finished_at, duration_ms, applied_by, host, dbkit_version, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.MigrationId, entry.Action, entry.Checksum, entry.StartedAt, entry.FinishedAt, entry.Duration.Milliseconds(), entry.AppliedBy, entry.Host, entry.Version, entry.Note)
+	return err
+}
+
+func (store *AppliedMigrationStore) History(ctx context.Context) ([]db.HistoryEntry, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version < schemaVersionHistory {
+		return []db.HistoryEntry{}, nil
+	}
+	rows, err := store.query(ctx, `
+		SELECT migration_id, action, COALESCE(checksum, ''), started_at, finished_at, duration_ms, COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '')
+		FROM {history}
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]db.HistoryEntry, 0)
+	for rows.Next() {
+		var entry db.HistoryEntry
+		var durationMs int64
+		err = rows.Scan(
+			&entry.MigrationId,
+			&entry.Action,
+			&entry.Checksum,
+			&entry.StartedAt,
+			&entry.FinishedAt,
+			&durationMs,
+			&entry.AppliedBy,
+			&entry.Host,
+			&entry.Version,
+			&entry.Note,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		results = append(results, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func expectOneRow(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return fmt.Errorf("expected 1 row affected, got %d", rows)
+	}
+	return nil
+}
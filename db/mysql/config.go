@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/easynow112/dbkit/config"
+)
+
+type Config struct {
+	Host     string
+	Port     int
+	Name     string
+	Password string
+	User     string
+	TLS      string
+	Table    string
+}
+
+func newConfig(portConfig *config.DriverConfig) (*Config, error) {
+
+	rawConfig := portConfig.Config
+
+	host, err := configString(rawConfig, "host")
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := configInt(rawConfig, "port")
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := configString(rawConfig, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := configString(rawConfig, "password")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := configString(rawConfig, "user")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := rawConfig["schema"]; ok {
+		return nil, fmt.Errorf("mysql driver does not support 'schema' in config, use 'name' to select the database")
+	}
+
+	tls := rawConfig["tls"]
+	if tls == "" {
+		tls = "false"
+	}
+
+	return &Config{
+		Host:     host,
+		Port:     port,
+		Name:     name,
+		Password: password,
+		User:     user,
+		TLS:      tls,
+		Table:    rawConfig["table"],
+	}, nil
+}
+
+func configString(rawConfig map[string]string, key string) (string, error) {
+	value, ok := rawConfig[key]
+	if !ok {
+		return "", fmt.Errorf("mysql driver requires '%s' string in config", key)
+	}
+	return value, nil
+}
+
+func configInt(rawConfig map[string]string, key string) (int, error) {
+	strVal, ok := rawConfig[key]
+	if !ok {
+		return 0, fmt.Errorf("mysql driver requires '%s' int in config", key)
+	}
+	parsed, err := strconv.Atoi(strVal)
+	if err != nil {
+		return 0, fmt.Errorf("mysql driver expects '%s' to be an int, received: %s", key, strVal)
+	}
+	return parsed, nil
+}
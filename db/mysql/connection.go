@@ -0,0 +1,205 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+type Connection struct {
+	db            *DB
+	conn          *sql.Conn
+	closed        atomic.Bool
+	trxInProgress atomic.Bool
+}
+
+func (c *Connection) TryAcquireLock(ctx context.Context) (db.Lock, error) {
+	if c.closed.Load() {
+		return nil, fmt.Errorf("connection is closed")
+	}
+	name := c.db.tables.lockName
+	var acquired sql.NullInt64
+	err := c.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&acquired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return nil, db.ErrLockHeld
+	}
+	if err := c.recordLockOwner(ctx); err != nil {
+		c.conn.ExecContext(context.WithoutCancel(ctx), "DO RELEASE_LOCK(?)", name)
+		return nil, fmt.Errorf("failed to record lock owner: %w", err)
+	}
+	return &Lock{
+		name: name,
+		conn: c.conn,
+	}, nil
+}
+
+func (c *Connection) recordLockOwner(ctx context.Context) error {
+	tables := c.db.tables
+	_, err := c.conn.ExecContext(ctx, tables.expand(`
+		CREATE TABLE IF NOT EXISTS {lock} (
+			id INTEGER PRIMARY KEY,
+			connection_id BIGINT NOT NULL,
+			owner_user VARCHAR(255),
+			owner_host VARCHAR(255),
+			owner_pid INTEGER,
+			locked_at DATETIME(6) NOT NULL
+		)
+	`))
+	if err != nil {
+		return err
+	}
+	owner := db.CurrentLockOwner()
+	_, err = c.conn.ExecContext(ctx, tables.expand(`
+		INSERT INTO {lock} (id, connection_id, owner_user, owner_host, owner_pid, locked_at)
+		VALUES (0, CONNECTION_ID(), ?, ?, ?, UTC_TIMESTAMP(6))
+		ON DUPLICATE KEY UPDATE
+			connection_id = VALUES(connection_id),
+			owner_user = VALUES(owner_user),
+			owner_host = VALUES(owner_host),
+			owner_pid = VALUES(owner_pid),
+			locked_at = VALUES(locked_at)
+	`), owner.User, owner.Host, owner.Pid)
+	return err
+}
+
+func (c *Connection) LockStatus(ctx context.Context) (db.LockStatus, error) {
+	if c.closed.Load() {
+		return db.LockStatus{}, fmt.Errorf("connection is closed")
+	}
+	var holder sql.NullInt64
+	err := c.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", c.db.tables.lockName).Scan(&holder)
+	if err != nil {
+		return db.LockStatus{}, fmt.Errorf("failed to inspect migration lock: %w", err)
+	}
+	if !holder.Valid {
+		return db.LockStatus{}, nil
+	}
+	status := db.LockStatus{
+		Held:       true,
+		BackendPid: int(holder.Int64),
+	}
+	var user, host sql.NullString
+	var pid sql.NullInt64
+	var since time.Time
+	err = c.conn.QueryRowContext(ctx, c.db.tables.expand(`
+		SELECT owner_user, owner_host, owner_pid, locked_at
+		FROM {lock}
+		WHERE id = 0 AND connection_id = ?
+	`), holder.Int64).Scan(&user, &host, &pid, &since)
+	if errors.Is(err, sql.ErrNoRows) || isMissingTable(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to read lock owner: %w", err)
+	}
+	status.Owner = db.LockOwner{
+		User: user.String,
+		Host: host.String,
+		Pid:  int(pid.Int64),
+	}
+	status.Since = &since
+	return status, nil
+}
+
+func (c *Connection) ForceReleaseLock(ctx context.Context, terminate bool) (db.LockStatus, error) {
+	status, err := c.LockStatus(ctx)
+	if err != nil || !status.Held {
+		return status, err
+	}
+	var ownId int
+	if err := c.conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&ownId); err != nil {
+		return status, err
+	}
+	if status.BackendPid == ownId {
+		for {
+			var released sql.NullInt64
+			err := c.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", c.db.tables.lockName).Scan(&released)
+			if err != nil {
+				return status, fmt.Errorf("failed to release migration lock: %w", err)
+			}
+			if !released.Valid || released.Int64 != 1 {
+				return status, nil
+			}
+		}
+	}
+	if !terminate {
+		return status, fmt.Errorf("%w (connection id %d)", db.ErrTerminateRequired, status.BackendPid)
+	}
+	if _, err := c.conn.ExecContext(ctx, fmt.Sprintf("KILL %d", status.BackendPid)); err != nil {
+		return status, fmt.Errorf("failed to kill connection %d: %w", status.BackendPid, err)
+	}
+	return status, db.WaitForRelease(ctx, c)
+}
+
+func isMissingTable(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146
+}
+
+func (c *Connection) AcquireLock(ctx context.Context, timeout time.Duration) (db.Lock, error) {
+	return db.WaitForLock(ctx, timeout, c.TryAcquireLock)
+}
+
+func (c *Connection) Close() error {
+	swapped := c.closed.CompareAndSwap(false, true)
+	if !swapped {
+		return nil
+	}
+	err := c.conn.Close()
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.connections--
+	// The driver already discarded a connection broken by a cancelled statement
+	if errors.Is(err, sql.ErrConnDone) {
+		return nil
+	}
+	return err
+}
+
+func (c *Connection) CommitsDDL() bool {
+	return true
+}
+
+func (c *Connection) AppliedMigrationStore() db.AppliedMigrationStore {
+	return &AppliedMigrationStore{
+		querier: c.conn,
+		tables:  c.db.tables,
+	}
+}
+
+func (c *Connection) Exec(ctx context.Context, query string, args ...any) (err error) {
+	if c.closed.Load() {
+		return fmt.Errorf("connection is closed")
+	}
+	_, err = c.conn.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (c *Connection) BeginTrx(ctx context.Context) (trx db.Transaction, err error) {
+	if c.closed.Load() {
+		return nil, fmt.Errorf("connection is closed")
+	}
+	if ok := c.trxInProgress.CompareAndSwap(false, true); !ok {
+		return nil, fmt.Errorf("transaction already in progress")
+	}
+
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		c.trxInProgress.Store(false)
+		return nil, err
+	}
+	return &Transaction{
+		tx:   tx,
+		conn: c,
+	}, nil
+}
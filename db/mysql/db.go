@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+type DB struct {
+	mu          sync.Mutex
+	connections int
+	sqlDB       *sql.DB
+	tables      *tables
+}
+
+func (db *DB) AcquireConnection(ctx context.Context) (db.Connection, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	conn, err := db.sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db.connections++
+	return &Connection{
+		conn: conn,
+		db:   db,
+	}, nil
+}
+
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.connections > 0 {
+		return fmt.Errorf("connection pool is not empty")
+	}
+	return db.sqlDB.Close()
+}
+
+// NewDB opens a MySQL connection pool. MySQL commits implicitly before and
+// after DDL statements and migrations run with multiple statements per Exec,
+// so a migration that fails after its first CREATE, ALTER or DROP keeps the
+// statements before the failure and its bookkeeping row may be committed
+// without it. Connections implement db.DDLCommitter so runs can warn about it.
+func NewDB(ctx context.Context, driverCfg *config.DriverConfig, _ *config.GlobalConfig) (db.DB, error) {
+	if driverCfg == nil {
+		return nil, fmt.Errorf("driver config is nil")
+	}
+	if driverCfg.Driver != "mysql" {
+		return nil, fmt.Errorf("invalid driver: %s", driverCfg.Driver)
+	}
+
+	mysqlConfig, err := newConfig(driverCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := newTables(mysqlConfig.Name, mysqlConfig.Table)
+	if err != nil {
+		return nil, fmt.Errorf("mysql driver: %w", err)
+	}
+
+	connConfig := gomysql.NewConfig()
+	connConfig.User = mysqlConfig.User
+	connConfig.Passwd = mysqlConfig.Password
+	connConfig.Net = "tcp"
+	connConfig.Addr = net.JoinHostPort(mysqlConfig.Host, strconv.Itoa(mysqlConfig.Port))
+	connConfig.DBName = mysqlConfig.Name
+	connConfig.TLSConfig = mysqlConfig.TLS
+	connConfig.MultiStatements = true
+	connConfig.ParseTime = true
+	connConfig.Loc = time.UTC
+
+	connector, err := gomysql.NewConnector(connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("mysql driver: %w", err)
+	}
+
+	sqlDB := sql.OpenDB(connector)
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	return &DB{
+		sqlDB:  sqlDB,
+		tables: tables,
+	}, nil
+}
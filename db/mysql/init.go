@@ -0,0 +1,9 @@
+package mysql
+
+import (
+	"github.com/easynow112/dbkit/db"
+)
+
+func init() {
+	db.RegisterDriver("mysql", NewDB)
+}
@@ -0,0 +1,28 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Lock struct {
+	name string
+	conn *sql.Conn
+}
+
+func (lock *Lock) Release(ctx context.Context) error {
+	var released sql.NullInt64
+	err := lock.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", lock.name).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+	if !released.Valid || released.Int64 != 1 {
+		return fmt.Errorf("lock was not held or already released")
+	}
+	return nil
+}
+
+func (lock *Lock) Lost() <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+)
+
+type schemaUpgrade func(ctx context.Context, store *AppliedMigrationStore) error
+
+var schemaUpgrades = []schemaUpgrade{
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {migrations} (
+			id VARCHAR(255) PRIMARY KEY,
+			checksum VARCHAR(255),
+			started_at DATETIME(6) NOT NULL,
+			finished_at DATETIME(6),
+			rollback_started_at DATETIME(6)
+		)
+	`),
+	addColumns([][2]string{
+		{"duration_ms", "BIGINT"},
+		{"applied_by", "VARCHAR(255)"},
+		{"host", "VARCHAR(255)"},
+		{"dbkit_version", "VARCHAR(255)"},
+		{"note", "TEXT"},
+	}),
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {history} (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			migration_id VARCHAR(255) NOT NULL,
+			action VARCHAR(32) NOT NULL,
+			checksum VARCHAR(255),
+			started_at DATETIME(6) NOT NULL,
+			finished_at DATETIME(6) NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			applied_by VARCHAR(255),
+			host VARCHAR(255),
+			dbkit_version VARCHAR(255),
+			note TEXT
+		)
+	`),
+}
+
+const (
+	schemaVersionMigrations = 1
+	schemaVersionRunInfo    = 2
+	schemaVersionHistory    = 3
+)
+
+func execStatements(statements ...string) schemaUpgrade {
+	return func(ctx context.Context, store *AppliedMigrationStore) error {
+		for _, statement := range statements {
+			if _, err := store.exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func addColumns(columns [][2]string) schemaUpgrade {
+	return func(ctx context.Context, store *AppliedMigrationStore) error {
+		for _, column := range columns {
+			name, definition := column[0], column[1]
+			var count int
+			err := store.querier.QueryRowContext(ctx, `
+				SELECT COUNT(*) FROM information_schema.columns
+				WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+			`, store.tables.Migrations, name).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if _, err := store.exec(ctx, fmt.Sprintf(`ALTER TABLE {migrations} ADD COLUMN %s %s`, name, definition)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (store *AppliedMigrationStore) upgradeSchema(ctx context.Context) error {
+	_, err := store.exec(ctx, `
+		CREATE TABLE IF NOT EXISTS {meta} (
+			id INTEGER PRIMARY KEY,
+			schema_version INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", store.tables.Meta, err)
+	}
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for ; version < len(schemaUpgrades); version++ {
+		if err := schemaUpgrades[version](ctx, store); err != nil {
+			return fmt.Errorf("failed to upgrade dbkit schema to version %d: %w", version+1, err)
+		}
+		_, err := store.exec(ctx, `
+			INSERT INTO {meta} (id, schema_version) VALUES (0, ?)
+			ON DUPLICATE KEY UPDATE schema_version = VALUES(schema_version)
+		`, version+1)
+		if err != nil {
+			return fmt.Errorf("failed to record dbkit schema version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+func (store *AppliedMigrationStore) schemaVersion(ctx context.Context) (int, error) {
+	metaExists, err := store.tableExists(ctx, store.tables.Meta)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check dbkit schema: %w", err)
+	}
+	if metaExists {
+		var version int
+		err := store.queryRow(ctx, `SELECT COALESCE(MAX(schema_version), 0) FROM {meta}`).Scan(&version)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read dbkit schema version: %w", err)
+		}
+		if version > 0 {
+			return version, nil
+		}
+	}
+	migrationsExists, err := store.tableExists(ctx, store.tables.Migrations)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check dbkit schema: %w", err)
+	}
+	if migrationsExists {
+		return schemaVersionMigrations, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+const maxLockNameLength = 64
+
+type tables struct {
+	db.Tables
+	lockName string
+	replacer *strings.Replacer
+}
+
+func newTables(database string, table string) (*tables, error) {
+	names, err := db.NewTables(table)
+	if err != nil {
+		return nil, err
+	}
+	return &tables{
+		Tables:   names,
+		lockName: lockName(database, names.Migrations),
+		replacer: strings.NewReplacer(
+			"{migrations}", quote(names.Migrations),
+			"{history}", quote(names.History),
+			"{meta}", quote(names.Meta),
+			"{lock}", quote(names.Lock),
+		),
+	}, nil
+}
+
+func lockName(database string, table string) string {
+	name := "dbkit." + database + "." + table
+	if len(name) <= maxLockNameLength {
+		return name
+	}
+	sum := sha1.Sum([]byte(name))
+	return "dbkit." + hex.EncodeToString(sum[:])
+}
+
+func quote(identifier string) string {
+	return "`" + identifier + "`"
+}
+
+func (t *tables) expand(query string) string {
+	return t.replacer.Replace(query)
+}
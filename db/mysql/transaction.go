@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+type Transaction struct {
+	conn *Connection
+	tx   *sql.Tx
+	done atomic.Bool
+}
+
+func (trx *Transaction) Commit(ctx context.Context) error {
+	if trx.done.Swap(true) {
+		return fmt.Errorf("no transaction in progress")
+	}
+	// The transaction is over even when the commit or rollback fails, keeping
+	// the flag set would refuse every later transaction on this connection
+	defer trx.conn.trxInProgress.Store(false)
+	return trx.tx.Commit()
+}
+
+func (trx *Transaction) Rollback(ctx context.Context) error {
+	if trx.done.Swap(true) {
+		return fmt.Errorf("no transaction in progress")
+	}
+	defer trx.conn.trxInProgress.Store(false)
+	return trx.tx.Rollback()
+}
+
+func (trx *Transaction) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := trx.tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (trx *Transaction) AppliedMigrationStore() db.AppliedMigrationStore {
+	return &AppliedMigrationStore{
+		querier: trx.tx,
+		tables:  trx.conn.db.tables,
+	}
+}
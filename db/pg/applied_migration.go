@@ -3,31 +3,84 @@ package pg
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/easynow112/dbkit/db"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type AppliedMigrationStore struct {
-	pgxConn *pgxpool.Conn
+	querier querier
+	tables  *tables
+}
+
+func (store *AppliedMigrationStore) exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return store.querier.Exec(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	return store.querier.Query(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) queryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	return store.querier.QueryRow(ctx, store.tables.expand(query), args...)
 }
 
 func (store *AppliedMigrationStore) EnsureSchema(ctx context.Context) error {
-	_, err := store.pgxConn.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS migrations (
-			id VARCHAR(255) PRIMARY KEY,
-			checksum VARCHAR(255),
-			started_at TIMESTAMPTZ NOT NULL,
-			finished_at TIMESTAMPTZ,
-			rollback_started_at TIMESTAMPTZ
-		);
-	`)
-	return err
+	return store.upgradeSchema(ctx)
+}
+
+func (store *AppliedMigrationStore) SchemaVersion(ctx context.Context) (current int, latest int, err error) {
+	current, err = store.schemaVersion(ctx)
+	return current, len(schemaUpgrades), err
+}
+
+func (store *AppliedMigrationStore) SchemaExists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := store.querier.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.tables
+			WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		)
+	`, store.tables.schema, store.tables.Migrations).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
 }
 
+const (
+	selectColumns       = `SELECT id, checksum, started_at, finished_at, rollback_started_at, duration_ms, COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '') FROM {migrations}`
+	selectLegacyColumns = `SELECT id, checksum, started_at, finished_at, rollback_started_at, NULL::BIGINT, '', '', '', '' FROM {migrations}`
+)
+
 func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigration, error) {
-	rows, err := store.pgxConn.Query(ctx, `SELECT id, checksum, started_at, finished_at, rollback_started_at FROM migrations ORDER BY migrations.started_at ASC`)
+	return store.list(ctx, ` ORDER BY started_at ASC, id ASC`)
+}
+
+func (store *AppliedMigrationStore) ListDirty(ctx context.Context) ([]db.AppliedMigration, error) {
+	return store.list(ctx, ` WHERE finished_at IS NULL OR rollback_started_at IS NOT NULL ORDER BY started_at ASC, id ASC`)
+}
+
+func (store *AppliedMigrationStore) list(ctx context.Context, clauses string) ([]db.AppliedMigration, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := selectColumns + clauses
+	if version < schemaVersionRunInfo {
+		query = selectLegacyColumns + clauses
+	}
+	rows, err := store.query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -35,16 +88,26 @@ func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigra
 	results := make([]db.AppliedMigration, 0)
 	for rows.Next() {
 		var row db.AppliedMigration
+		var durationMs *int64
 		err = rows.Scan(
 			&row.Id,
 			&row.Checksum,
 			&row.StartedAt,
 			&row.FinishedAt,
 			&row.RollbackStartedAt,
+			&durationMs,
+			&row.AppliedBy,
+			&row.Host,
+			&row.Version,
+			&row.Note,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if durationMs != nil {
+			duration := time.Duration(*durationMs) * time.Millisecond
+			row.Duration = &duration
+		}
 		results = append(results, row)
 	}
 
@@ -57,7 +120,7 @@ func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigra
 }
 
 func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error {
-	cmdTag, err := store.pgxConn.Exec(ctx, `DELETE FROM migrations WHERE id = $1`, id)
+	cmdTag, err := store.exec(ctx, `DELETE FROM {migrations} WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
@@ -67,8 +130,8 @@ func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string) error {
-	cmdTag, err := store.pgxConn.Exec(ctx, `INSERT INTO migrations (id, checksum, started_at) VALUES ($1, $2, NOW())`, id, checksum)
+func (store *AppliedMigrationStore) MarkApplied(ctx context.Context, id string) error {
+	cmdTag, err := store.exec(ctx, `UPDATE {migrations} SET finished_at = COALESCE(finished_at, NOW()), rollback_started_at = NULL WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
@@ -78,8 +141,19 @@ func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string) error {
-	cmdTag, err := store.pgxConn.Exec(ctx, `UPDATE migrations SET finished_at = NOW() WHERE id = $1`, id)
+func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string, info db.RunInfo) error {
+	cmdTag, err := store.exec(ctx, `INSERT INTO {migrations} (id, checksum, started_at, applied_by, host, dbkit_version, note) VALUES ($1, $2, NOW(), $3, $4, $5, $6)`, id, checksum, info.AppliedBy, info.Host, info.Version, info.Note)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return fmt.Errorf("expected 1 row affected, got %d", cmdTag.RowsAffected())
+	}
+	return nil
+}
+
+func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string, duration time.Duration) error {
+	cmdTag, err := store.exec(ctx, `UPDATE {migrations} SET finished_at = NOW(), duration_ms = $2 WHERE id = $1`, id, duration.Milliseconds())
 	if err != nil {
 		return err
 	}
@@ -90,7 +164,7 @@ func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id strin
 }
 
 func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, id string) error {
-	cmdTag, err := store.pgxConn.Exec(ctx, `UPDATE migrations SET rollback_started_at = NOW() WHERE id = $1`, id)
+	cmdTag, err := store.exec(ctx, `UPDATE {migrations} SET rollback_started_at = NOW() WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
@@ -99,3 +173,59 @@ func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, i
 	}
 	return nil
 }
+
+func (store *AppliedMigrationStore) RecordHistory(ctx context.Context, entry db.HistoryEntry) error {
+	_, err := store.exec(ctx, `
+		INSERT INTO {history} (migration_id, action, checksum, started_at, finished_at, duration_ms, applied_by, host, dbkit_version, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, entry.MigrationId, entry.Action, entry.Checksum, entry.StartedAt, entry.FinishedAt, entry.Duration.Milliseconds(), entry.AppliedBy, entry.Host, entry.Version, entry.Note)
+	return err
+}
+
+func (store *AppliedMigrationStore) History(ctx context.Context) ([]db.HistoryEntry, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version < schemaVersionHistory {
+		return []db.HistoryEntry{}, nil
+	}
+	rows, err := store.query(ctx, `
+		SELECT migration_id, action, COALESCE(checksum, ''), started_at, finished_at, duration_ms, COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '')
+		FROM {history}
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]db.HistoryEntry, 0)
+	for rows.Next() {
+		var entry db.HistoryEntry
+		var durationMs int64
+		err = rows.Scan(
+			&entry.MigrationId,
+			&entry.Action,
+			&entry.Checksum,
+			&entry.StartedAt,
+			&entry.FinishedAt,
+			&durationMs,
+			&entry.AppliedBy,
+			&entry.Host,
+			&entry.Version,
+			&entry.Note,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		results = append(results, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
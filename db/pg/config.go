@@ -1,25 +1,80 @@
 package pg
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/easynow112/dbkit/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Config struct {
-	Host     string
-	Port     int
-	Name     string
-	Password string
-	User     string
-	SSL      string
+	ConnString string
+	ConnKey    string
+	Password   string
+	Table      string
+	Schema     string
+	base       string
+	params     map[string]string
 }
 
+var (
+	connStringKeys = []string{"url", "dsn"}
+	connectionKeys = []string{"host", "port", "user", "password", "name", "ssl"}
+	driverKeys     = []string{"table", "schema"}
+)
+
 func newConfig(portConfig *config.DriverConfig) (config *Config, err error) {
 
 	rawConfig := portConfig.Config
 
+	params := make(map[string]string)
+	for key, value := range rawConfig {
+		if !slices.Contains(connStringKeys, key) && !slices.Contains(connectionKeys, key) && !slices.Contains(driverKeys, key) {
+			params[key] = value
+		}
+	}
+
+	connKey := ""
+	for _, key := range connStringKeys {
+		if _, ok := rawConfig[key]; !ok {
+			continue
+		}
+		if connKey != "" {
+			return nil, fmt.Errorf("pg driver accepts either '%s' or '%s' in config, not both", connKey, key)
+		}
+		connKey = key
+	}
+
+	if connKey != "" {
+		for _, key := range connectionKeys {
+			if _, ok := rawConfig[key]; ok {
+				return nil, fmt.Errorf("pg driver does not allow '%s' together with '%s' in config", key, connKey)
+			}
+		}
+		connString, password, err := withParams(connKey, rawConfig[connKey], params)
+		if err != nil {
+			return nil, err
+		}
+		return &Config{
+			ConnString: connString,
+			ConnKey:    connKey,
+			Password:   password,
+			Table:      rawConfig["table"],
+			Schema:     rawConfig["schema"],
+			base:       rawConfig[connKey],
+			params:     params,
+		}, nil
+	}
+
 	host, err := configString(rawConfig, "host")
 	if err != nil {
 		return nil, err
@@ -50,16 +105,154 @@ func newConfig(portConfig *config.DriverConfig) (config *Config, err error) {
 		return nil, err
 	}
 
+	base := keywordValues(map[string]string{
+		"user":     user,
+		"password": password,
+		"host":     host,
+		"port":     strconv.Itoa(port),
+		"dbname":   name,
+		"sslmode":  ssl,
+	})
+	connString, _, err := withParams("", base, params)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Host:     host,
-		Port:     port,
-		Name:     name,
-		Password: password,
-		User:     user,
-		SSL:      ssl,
+		ConnString: connString,
+		Password:   password,
+		Table:      rawConfig["table"],
+		Schema:     rawConfig["schema"],
+		base:       base,
+		params:     params,
 	}, nil
 }
 
+func (cfg *Config) parse() (*pgxpool.Config, error) {
+	pgxConfig, err := pgxpool.ParseConfig(cfg.ConnString)
+	if err != nil {
+		return nil, parseError(cfg.failingKey(), cfg.Password, err)
+	}
+	return pgxConfig, nil
+}
+
+// failingKey names the extra param that makes the connection string invalid,
+// falling back to the connection key when the params are not at fault.
+func (cfg *Config) failingKey() string {
+	if _, err := pgxpool.ParseConfig(cfg.base); err != nil {
+		return cfg.ConnKey
+	}
+	keys := slices.Sorted(maps.Keys(cfg.params))
+	for _, key := range keys {
+		connString, _, err := withParams(cfg.ConnKey, cfg.base, map[string]string{key: cfg.params[key]})
+		if err != nil {
+			return cfg.ConnKey
+		}
+		if _, err := pgxpool.ParseConfig(connString); err != nil {
+			return key
+		}
+	}
+	return cfg.ConnKey
+}
+
+func isURL(connString string) bool {
+	return strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://")
+}
+
+func withParams(key string, connString string, params map[string]string) (string, string, error) {
+	if !isURL(connString) {
+		password := keywordPassword(connString)
+		if len(params) == 0 {
+			return connString, password, nil
+		}
+		return connString + " " + keywordValues(params), password, nil
+	}
+	u, err := url.Parse(connString)
+	if err != nil {
+		return "", "", parseError(key, urlPassword(connString), err)
+	}
+	password, _ := u.User.Password()
+	query := u.Query()
+	for name, value := range params {
+		query.Set(name, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), password, nil
+}
+
+// urlPassword reads the password from a url that url.Parse rejected, so it
+// can still be redacted from the parse error.
+func urlPassword(connString string) string {
+	_, rest, _ := strings.Cut(connString, "://")
+	at := strings.LastIndex(rest, "@")
+	if at == -1 {
+		return ""
+	}
+	_, password, _ := strings.Cut(rest[:at], ":")
+	return password
+}
+
+var keywordPasswordPattern = regexp.MustCompile(`(?:^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+func keywordPassword(connString string) string {
+	match := keywordPasswordPattern.FindStringSubmatch(connString)
+	if match == nil {
+		return ""
+	}
+	value := match[1]
+	if strings.HasPrefix(value, "'") {
+		value = strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(value[1 : len(value)-1])
+	}
+	return value
+}
+
+func keywordValues(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(params[key])
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, value))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func parseError(key string, password string, err error) error {
+	detail := err.Error()
+	var parseErr *pgconn.ParseConfigError
+	if errors.As(err, &parseErr) {
+		inner := parseErr.Unwrap()
+		detail = parseErr.Error()
+		if i := strings.LastIndex(detail, "`: "); i >= 0 {
+			detail = detail[i+3:]
+		}
+		if inner != nil {
+			detail = strings.TrimSuffix(detail, " ("+inner.Error()+")")
+			detail = fmt.Sprintf("%s (%s)", detail, redactedError(inner))
+		}
+	} else {
+		detail = redactedError(err)
+	}
+	if password != "" {
+		detail = strings.ReplaceAll(detail, password, "xxxxx")
+	}
+	if key == "" {
+		return fmt.Errorf("pg driver could not parse connection config: %s", detail)
+	}
+	return fmt.Errorf("pg driver could not parse '%s' in config: %s", key, detail)
+}
+
+func redactedError(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
 func configString(rawConfig map[string]string, key string) (string, error) {
 	value, ok := rawConfig[key]
 	if !ok {
@@ -2,8 +2,10 @@ package pg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/easynow112/dbkit/db"
 
@@ -28,14 +30,18 @@ func (conn *Connection) TryAcquireLock(ctx context.Context) (lock db.Lock, err e
 			err = fmt.Errorf("panic while acquiring lock: %v", r)
 		}
 	}()
-	const id int = 3955278872
+	id := conn.db.tables.lockKey()
 	var acquired bool
 	err = conn.pgxConn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&acquired)
 	if err != nil {
 		return nil, err
 	}
 	if !acquired {
-		return nil, fmt.Errorf("another migration process is already running")
+		return nil, db.ErrLockHeld
+	}
+	if err := conn.recordLockOwner(ctx); err != nil {
+		conn.pgxConn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", id)
+		return nil, fmt.Errorf("failed to record lock owner: %w", err)
 	}
 	return &Lock{
 		id:      id,
@@ -43,6 +49,133 @@ func (conn *Connection) TryAcquireLock(ctx context.Context) (lock db.Lock, err e
 	}, nil
 }
 
+func (conn *Connection) recordLockOwner(ctx context.Context) error {
+	tables := conn.db.tables
+	if tables.schema != "" {
+		if _, err := conn.pgxConn.Exec(ctx, tables.createSchema()); err != nil {
+			return err
+		}
+	}
+	_, err := conn.pgxConn.Exec(ctx, tables.expand(`
+		CREATE TABLE IF NOT EXISTS {lock} (
+			id INTEGER PRIMARY KEY CHECK (id = 0),
+			backend_pid INTEGER NOT NULL,
+			owner_user VARCHAR(255),
+			owner_host VARCHAR(255),
+			owner_pid INTEGER,
+			locked_at TIMESTAMPTZ NOT NULL
+		)
+	`))
+	if err != nil {
+		return err
+	}
+	owner := db.CurrentLockOwner()
+	_, err = conn.pgxConn.Exec(ctx, tables.expand(`
+		INSERT INTO {lock} (id, backend_pid, owner_user, owner_host, owner_pid, locked_at)
+		VALUES (0, pg_backend_pid(), $1, $2, $3, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			backend_pid = EXCLUDED.backend_pid,
+			owner_user = EXCLUDED.owner_user,
+			owner_host = EXCLUDED.owner_host,
+			owner_pid = EXCLUDED.owner_pid,
+			locked_at = EXCLUDED.locked_at
+	`), owner.User, owner.Host, owner.Pid)
+	return err
+}
+
+func (conn *Connection) LockStatus(ctx context.Context) (db.LockStatus, error) {
+	if conn.closed.Load() {
+		return db.LockStatus{}, fmt.Errorf("connection is closed")
+	}
+	key := uint64(conn.db.tables.lockKey())
+	var backendPid int
+	err := conn.pgxConn.QueryRow(ctx, `
+		SELECT pid FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND objsubid = 1
+			AND database = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND classid = $1::bigint::oid AND objid = $2::bigint::oid
+		LIMIT 1
+	`, int64(key>>32), int64(key&0xffffffff)).Scan(&backendPid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.LockStatus{}, nil
+	}
+	if err != nil {
+		return db.LockStatus{}, fmt.Errorf("failed to inspect advisory locks: %w", err)
+	}
+	status := db.LockStatus{
+		Held:       true,
+		BackendPid: backendPid,
+	}
+	var exists bool
+	err = conn.pgxConn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, conn.db.tables.expand("{lock}")).Scan(&exists)
+	if err != nil || !exists {
+		return status, err
+	}
+	var user, host *string
+	var pid *int
+	var since time.Time
+	err = conn.pgxConn.QueryRow(ctx, conn.db.tables.expand(`
+		SELECT owner_user, owner_host, owner_pid, locked_at
+		FROM {lock}
+		WHERE id = 0 AND backend_pid = $1
+	`), backendPid).Scan(&user, &host, &pid, &since)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to read lock owner: %w", err)
+	}
+	if user != nil {
+		status.Owner.User = *user
+	}
+	if host != nil {
+		status.Owner.Host = *host
+	}
+	if pid != nil {
+		status.Owner.Pid = *pid
+	}
+	status.Since = &since
+	return status, nil
+}
+
+func (conn *Connection) ForceReleaseLock(ctx context.Context, terminate bool) (db.LockStatus, error) {
+	status, err := conn.LockStatus(ctx)
+	if err != nil || !status.Held {
+		return status, err
+	}
+	var ownPid int
+	if err := conn.pgxConn.QueryRow(ctx, "SELECT pg_backend_pid()").Scan(&ownPid); err != nil {
+		return status, err
+	}
+	if status.BackendPid == ownPid {
+		id := conn.db.tables.lockKey()
+		for {
+			var released bool
+			if err := conn.pgxConn.QueryRow(ctx, "SELECT pg_advisory_unlock($1)", id).Scan(&released); err != nil {
+				return status, fmt.Errorf("failed to release migration lock: %w", err)
+			}
+			if !released {
+				return status, nil
+			}
+		}
+	}
+	if !terminate {
+		return status, fmt.Errorf("%w (backend pid %d)", db.ErrTerminateRequired, status.BackendPid)
+	}
+	var terminated bool
+	if err := conn.pgxConn.QueryRow(ctx, "SELECT pg_terminate_backend($1)", status.BackendPid).Scan(&terminated); err != nil {
+		return status, fmt.Errorf("failed to terminate backend %d: %w", status.BackendPid, err)
+	}
+	if !terminated {
+		return status, fmt.Errorf("failed to terminate backend %d", status.BackendPid)
+	}
+	return status, db.WaitForRelease(ctx, conn)
+}
+
+func (conn *Connection) AcquireLock(ctx context.Context, timeout time.Duration) (db.Lock, error) {
+	return db.WaitForLock(ctx, timeout, conn.TryAcquireLock)
+}
+
 func (conn *Connection) Close() error {
 	swapped := conn.closed.CompareAndSwap(false, true)
 	if !swapped {
@@ -57,7 +190,8 @@ func (conn *Connection) Close() error {
 
 func (conn *Connection) AppliedMigrationStore() db.AppliedMigrationStore {
 	return &AppliedMigrationStore{
-		pgxConn: conn.pgxConn,
+		querier: conn.pgxConn,
+		tables:  conn.db.tables,
 	}
 }
 
@@ -74,6 +208,18 @@ func (conn *Connection) Exec(ctx context.Context, query string, args ...any) (er
 	return err
 }
 
+func (conn *Connection) SetStatementTimeout(ctx context.Context, timeout time.Duration) error {
+	if conn.closed.Load() {
+		return fmt.Errorf("connection is closed")
+	}
+	if timeout == 0 {
+		_, err := conn.pgxConn.Exec(ctx, "RESET statement_timeout")
+		return err
+	}
+	_, err := conn.pgxConn.Exec(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds()))
+	return err
+}
+
 func (conn *Connection) BeginTrx(ctx context.Context) (trx db.Transaction, err error) {
 	if conn.closed.Load() {
 		return nil, fmt.Errorf("connection is closed")
@@ -86,6 +232,9 @@ func (conn *Connection) BeginTrx(ctx context.Context) (trx db.Transaction, err e
 			trx = nil
 			err = fmt.Errorf("panic while beginning transaction: %v", r)
 		}
+		if err != nil {
+			conn.trxInProgress.Store(false)
+		}
 	}()
 	pgTrx, err := conn.pgxConn.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
@@ -4,17 +4,23 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/easynow112/dbkit/config"
 	"github.com/easynow112/dbkit/db"
 
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const cancelDeadlineDelay = 5 * time.Second
+
 type DB struct {
 	mu          sync.Mutex
 	connections int
 	pgxPool     *pgxpool.Pool
+	tables      *tables
 }
 
 func (db *DB) AcquireConnection(ctx context.Context) (db.Connection, error) {
@@ -54,19 +60,24 @@ func NewDB(ctx context.Context, driverCfg *config.DriverConfig, _ *config.Global
 		return nil, err
 	}
 
-	connStr := fmt.Sprintf(
-		"user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
-		pgConfig.User,
-		pgConfig.Password,
-		pgConfig.Host,
-		pgConfig.Port,
-		pgConfig.Name,
-		pgConfig.SSL,
-	)
-	pgxConfig, err := pgxpool.ParseConfig(connStr)
+	tables, err := newTables(pgConfig.Schema, pgConfig.Table)
+	if err != nil {
+		return nil, fmt.Errorf("pg driver: %w", err)
+	}
+
+	pgxConfig, err := pgConfig.parse()
 	if err != nil {
 		return nil, err
 	}
+	// Cancel interrupted statements on the server instead of closing the
+	// connection, so a cancelled migration can still roll back and release
+	// its session state
+	pgxConfig.ConnConfig.BuildContextWatcherHandler = func(pgConn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{
+			Conn:          pgConn,
+			DeadlineDelay: cancelDeadlineDelay,
+		}
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, pgxConfig)
 	if err != nil {
@@ -80,5 +91,6 @@ func NewDB(ctx context.Context, driverCfg *config.DriverConfig, _ *config.Global
 
 	return &DB{
 		pgxPool: pool,
+		tables:  tables,
 	}, nil
 }
@@ -7,7 +7,7 @@ import (
 )
 
 type Lock struct {
-	id      int
+	id      int64
 	pgxConn *pgxpool.Conn
 }
 
@@ -15,3 +15,7 @@ func (lock *Lock) Release(ctx context.Context) error {
 	_, err := lock.pgxConn.Exec(ctx, "SELECT pg_advisory_unlock($1)", lock.id)
 	return err
 }
+
+func (lock *Lock) Lost() <-chan struct{} {
+	return nil
+}
@@ -0,0 +1,139 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+type schemaUpgrade func(ctx context.Context, store *AppliedMigrationStore) error
+
+var schemaUpgrades = []schemaUpgrade{
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {migrations} (
+			id VARCHAR(255) PRIMARY KEY,
+			checksum VARCHAR(255),
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ,
+			rollback_started_at TIMESTAMPTZ
+		)
+	`),
+	execStatements(
+		`ALTER TABLE {migrations} ADD COLUMN IF NOT EXISTS duration_ms BIGINT`,
+		`ALTER TABLE {migrations} ADD COLUMN IF NOT EXISTS applied_by VARCHAR(255)`,
+		`ALTER TABLE {migrations} ADD COLUMN IF NOT EXISTS host VARCHAR(255)`,
+		`ALTER TABLE {migrations} ADD COLUMN IF NOT EXISTS dbkit_version VARCHAR(255)`,
+		`ALTER TABLE {migrations} ADD COLUMN IF NOT EXISTS note TEXT`,
+	),
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {history} (
+			id BIGSERIAL PRIMARY KEY,
+			migration_id VARCHAR(255) NOT NULL,
+			action VARCHAR(32) NOT NULL,
+			checksum VARCHAR(255),
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			applied_by VARCHAR(255),
+			host VARCHAR(255),
+			dbkit_version VARCHAR(255),
+			note TEXT
+		)
+	`),
+}
+
+const (
+	schemaVersionMigrations = 1
+	schemaVersionRunInfo    = 2
+	schemaVersionHistory    = 3
+)
+
+func execStatements(statements ...string) schemaUpgrade {
+	return func(ctx context.Context, store *AppliedMigrationStore) error {
+		for _, statement := range statements {
+			if _, err := store.exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (store *AppliedMigrationStore) upgradeSchema(ctx context.Context) error {
+	if store.tables.schema != "" {
+		if _, err := store.querier.Exec(ctx, store.tables.createSchema()); err != nil {
+			return fmt.Errorf("failed to create schema %s: %w", store.tables.schema, err)
+		}
+	}
+	_, err := store.exec(ctx, `
+		CREATE TABLE IF NOT EXISTS {meta} (
+			id INTEGER PRIMARY KEY CHECK (id = 0),
+			schema_version INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", store.tables.Meta, err)
+	}
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for ; version < len(schemaUpgrades); version++ {
+		if err := store.applySchemaUpgrade(ctx, version+1); err != nil {
+			return fmt.Errorf("failed to upgrade dbkit schema to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+func (store *AppliedMigrationStore) applySchemaUpgrade(ctx context.Context, version int) (err error) {
+	tx, err := store.querier.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+		}
+	}()
+	txStore := &AppliedMigrationStore{
+		querier: tx,
+		tables:  store.tables,
+	}
+	if err = schemaUpgrades[version-1](ctx, txStore); err != nil {
+		return err
+	}
+	_, err = txStore.exec(ctx, `
+		INSERT INTO {meta} (id, schema_version) VALUES (0, $1)
+		ON CONFLICT (id) DO UPDATE SET schema_version = EXCLUDED.schema_version
+	`, version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (store *AppliedMigrationStore) schemaVersion(ctx context.Context) (int, error) {
+	var metaExists, migrationsExists bool
+	err := store.querier.QueryRow(ctx, `
+		SELECT
+			EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2),
+			EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $3)
+	`, store.tables.schema, store.tables.Meta, store.tables.Migrations).Scan(&metaExists, &migrationsExists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check dbkit schema: %w", err)
+	}
+	if metaExists {
+		var version int
+		err := store.queryRow(ctx, `SELECT COALESCE(MAX(schema_version), 0) FROM {meta}`).Scan(&version)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read dbkit schema version: %w", err)
+		}
+		if version > 0 {
+			return version, nil
+		}
+	}
+	if migrationsExists {
+		return schemaVersionMigrations, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,65 @@
+package pg
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/easynow112/dbkit/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultLockKey int64 = 3955278872
+
+type tables struct {
+	db.Tables
+	schema   string
+	replacer *strings.Replacer
+}
+
+func newTables(schema string, table string) (*tables, error) {
+	names, err := db.NewTables(table)
+	if err != nil {
+		return nil, err
+	}
+	if schema != "" {
+		if err := db.ValidateIdentifier("schema", schema); err != nil {
+			return nil, err
+		}
+	}
+	t := &tables{
+		Tables: names,
+		schema: schema,
+	}
+	t.replacer = strings.NewReplacer(
+		"{migrations}", t.qualified(names.Migrations),
+		"{history}", t.qualified(names.History),
+		"{meta}", t.qualified(names.Meta),
+		"{lock}", t.qualified(names.Lock),
+	)
+	return t, nil
+}
+
+func (t *tables) qualified(name string) string {
+	if t.schema == "" {
+		return pgx.Identifier{name}.Sanitize()
+	}
+	return pgx.Identifier{t.schema, name}.Sanitize()
+}
+
+func (t *tables) expand(query string) string {
+	return t.replacer.Replace(query)
+}
+
+func (t *tables) createSchema() string {
+	return `CREATE SCHEMA IF NOT EXISTS ` + pgx.Identifier{t.schema}.Sanitize()
+}
+
+func (t *tables) lockKey() int64 {
+	if t.schema == "" && t.Migrations == db.DefaultTable {
+		return defaultLockKey
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(t.schema + "." + t.Migrations))
+	return int64(hash.Sum64())
+}
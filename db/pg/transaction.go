@@ -3,6 +3,10 @@ package pg
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -10,29 +14,44 @@ import (
 type Transaction struct {
 	conn   *Connection
 	pgxTrx pgx.Tx
+	done   atomic.Bool
 }
 
 func (trx *Transaction) Commit(ctx context.Context) error {
-	if err := trx.pgxTrx.Commit(ctx); err != nil {
-		return err
-	}
-	if ok := trx.conn.trxInProgress.CompareAndSwap(true, false); !ok {
+	if trx.done.Swap(true) {
 		return fmt.Errorf("no transaction in progress")
 	}
-	return nil
+	// pgx closes the transaction even when the commit or rollback fails, keeping
+	// the flag set would refuse every later transaction on this connection
+	defer trx.conn.trxInProgress.Store(false)
+	return trx.pgxTrx.Commit(ctx)
 }
 
 func (trx *Transaction) Rollback(ctx context.Context) error {
-	if err := trx.pgxTrx.Rollback(ctx); err != nil {
-		return err
-	}
-	if ok := trx.conn.trxInProgress.CompareAndSwap(true, false); !ok {
+	if trx.done.Swap(true) {
 		return fmt.Errorf("no transaction in progress")
 	}
-	return nil
+	defer trx.conn.trxInProgress.Store(false)
+	return trx.pgxTrx.Rollback(ctx)
 }
 
 func (trx *Transaction) Exec(ctx context.Context, query string, args ...any) error {
 	_, err := trx.pgxTrx.Exec(ctx, query, args...)
 	return err
 }
+
+func (trx *Transaction) SetStatementTimeout(ctx context.Context, timeout time.Duration) error {
+	if timeout == 0 {
+		_, err := trx.pgxTrx.Exec(ctx, "SET LOCAL statement_timeout TO DEFAULT")
+		return err
+	}
+	_, err := trx.pgxTrx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds()))
+	return err
+}
+
+func (trx *Transaction) AppliedMigrationStore() db.AppliedMigrationStore {
+	return &AppliedMigrationStore{
+		querier: trx.pgxTrx,
+		tables:  trx.conn.db.tables,
+	}
+}
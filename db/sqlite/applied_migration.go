@@ -9,8 +9,27 @@ import (
 	"github.com/easynow112/dbkit/db"
 )
 
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type AppliedMigrationStore struct {
-	conn *sql.Conn
+	querier querier
+	tables  *tables
+}
+
+func (store *AppliedMigrationStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return store.querier.ExecContext(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return store.querier.QueryContext(ctx, store.tables.expand(query), args...)
+}
+
+func (store *AppliedMigrationStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return store.querier.QueryRowContext(ctx, store.tables.expand(query), args...)
 }
 
 type rowDto struct {
@@ -19,6 +38,11 @@ type rowDto struct {
 	StartedAt         int64
 	FinishedAt        *int64
 	RollbackStartedAt *int64
+	DurationMs        *int64
+	AppliedBy         string
+	Host              string
+	Version           string
+	Note              string
 }
 
 func (r rowDto) appliedMigration() db.AppliedMigration {
@@ -32,30 +56,81 @@ func (r rowDto) appliedMigration() db.AppliedMigration {
 		t := time.Unix(*r.RollbackStartedAt, 0)
 		rollbackStartedAt = &t
 	}
+	var duration *time.Duration
+	if r.DurationMs != nil {
+		d := time.Duration(*r.DurationMs) * time.Millisecond
+		duration = &d
+	}
 	return db.AppliedMigration{
 		Id:                r.Id,
 		Checksum:          r.Checksum,
 		StartedAt:         time.Unix(r.StartedAt, 0),
 		FinishedAt:        finishedAt,
 		RollbackStartedAt: rollbackStartedAt,
+		Duration:          duration,
+		RunInfo: db.RunInfo{
+			AppliedBy: r.AppliedBy,
+			Host:      r.Host,
+			Version:   r.Version,
+			Note:      r.Note,
+		},
 	}
 }
 
 func (store *AppliedMigrationStore) EnsureSchema(ctx context.Context) error {
-	_, err := store.conn.ExecContext(ctx, `
-		CREATE TABLE IF NOT EXISTS migrations (
-			id TEXT PRIMARY KEY,
-			checksum TEXT,
-			started_at INTEGER NOT NULL,
-			finished_at INTEGER,
-			rollback_started_at INTEGER
-		);
-	`)
-	return err
+	return store.upgradeSchema(ctx)
 }
 
+func (store *AppliedMigrationStore) SchemaVersion(ctx context.Context) (current int, latest int, err error) {
+	current, err = store.schemaVersion(ctx)
+	return current, len(schemaUpgrades), err
+}
+
+func (store *AppliedMigrationStore) SchemaExists(ctx context.Context) (bool, error) {
+	var count int
+	err := store.querier.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = ?
+	`, store.tables.Migrations).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+const (
+	selectColumns = `
+		SELECT id, checksum, started_at, finished_at, rollback_started_at, duration_ms,
+			COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '')
+		FROM {migrations}
+	`
+	selectLegacyColumns = `
+		SELECT id, checksum, started_at, finished_at, rollback_started_at, NULL, '', '', '', ''
+		FROM {migrations}
+	`
+)
+
 func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigration, error) {
-	rows, err := store.conn.QueryContext(ctx, `SELECT id, checksum, started_at, finished_at, rollback_started_at FROM migrations ORDER BY migrations.started_at ASC`)
+	return store.list(ctx, `ORDER BY started_at ASC, rowid ASC`)
+}
+
+func (store *AppliedMigrationStore) ListDirty(ctx context.Context) ([]db.AppliedMigration, error) {
+	return store.list(ctx, `
+		WHERE finished_at IS NULL OR rollback_started_at IS NOT NULL
+		ORDER BY started_at ASC, rowid ASC
+	`)
+}
+
+func (store *AppliedMigrationStore) list(ctx context.Context, clauses string) ([]db.AppliedMigration, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := selectColumns + clauses
+	if version < schemaVersionRunInfo {
+		query = selectLegacyColumns + clauses
+	}
+	rows, err := store.query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +144,11 @@ func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigra
 			&row.StartedAt,
 			&row.FinishedAt,
 			&row.RollbackStartedAt,
+			&row.DurationMs,
+			&row.AppliedBy,
+			&row.Host,
+			&row.Version,
+			&row.Note,
 		); err != nil {
 			return nil, err
 		}
@@ -82,8 +162,8 @@ func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigra
 }
 
 func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error {
-	res, err := store.conn.ExecContext(ctx, `
-		DELETE FROM migrations
+	res, err := store.exec(ctx, `
+		DELETE FROM {migrations}
 		WHERE id = ?
 	`, id)
 	if err != nil {
@@ -99,11 +179,13 @@ func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string) error {
-	res, err := store.conn.ExecContext(ctx, `
-			INSERT INTO migrations (id, checksum, started_at)
-			VALUES (?, ?, unixepoch('now'))
-		`, id, checksum)
+func (store *AppliedMigrationStore) MarkApplied(ctx context.Context, id string) error {
+	res, err := store.exec(ctx, `
+		UPDATE {migrations}
+		SET finished_at = COALESCE(finished_at, unixepoch('now')),
+		    rollback_started_at = NULL
+		WHERE id = ?
+	`, id)
 	if err != nil {
 		return err
 	}
@@ -117,12 +199,31 @@ func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string) error {
-	res, err := store.conn.ExecContext(ctx, `
-		UPDATE migrations
-		SET finished_at = unixepoch('now')
+func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string, info db.RunInfo) error {
+	res, err := store.exec(ctx, `
+			INSERT INTO {migrations} (id, checksum, started_at, applied_by, host, dbkit_version, note)
+			VALUES (?, ?, unixepoch('now'), ?, ?, ?, ?)
+		`, id, checksum, info.AppliedBy, info.Host, info.Version, info.Note)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return fmt.Errorf("expected 1 row affected, got %d", rows)
+	}
+	return nil
+}
+
+func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string, duration time.Duration) error {
+	res, err := store.exec(ctx, `
+		UPDATE {migrations}
+		SET finished_at = unixepoch('now'),
+		    duration_ms = ?
 		WHERE id = ?
-	`, id)
+	`, duration.Milliseconds(), id)
 	if err != nil {
 		return err
 	}
@@ -137,8 +238,8 @@ func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id strin
 }
 
 func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, id string) error {
-	res, err := store.conn.ExecContext(ctx, `
-		UPDATE migrations
+	res, err := store.exec(ctx, `
+		UPDATE {migrations}
 		SET rollback_started_at = unixepoch('now')
 		WHERE id = ?
 	`, id)
@@ -154,3 +255,59 @@ func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, i
 	}
 	return nil
 }
+
+func (store *AppliedMigrationStore) RecordHistory(ctx context.Context, entry db.HistoryEntry) error {
+	_, err := store.exec(ctx, `
+		INSERT INTO {history} (migration_id, action, checksum, started_at, finished_at, duration_ms, applied_by, host, dbkit_version, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.MigrationId, entry.Action, entry.Checksum, entry.StartedAt.Unix(), entry.FinishedAt.Unix(), entry.Duration.Milliseconds(), entry.AppliedBy, entry.Host, entry.Version, entry.Note)
+	return err
+}
+
+func (store *AppliedMigrationStore) History(ctx context.Context) ([]db.HistoryEntry, error) {
+	version, err := store.schemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version < schemaVersionHistory {
+		return []db.HistoryEntry{}, nil
+	}
+	rows, err := store.query(ctx, `
+		SELECT migration_id, action, COALESCE(checksum, ''), started_at, finished_at, duration_ms,
+			COALESCE(applied_by, ''), COALESCE(host, ''), COALESCE(dbkit_version, ''), COALESCE(note, '')
+		FROM {history}
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]db.HistoryEntry, 0)
+	for rows.Next() {
+		var entry db.HistoryEntry
+		var startedAt, finishedAt, durationMs int64
+		if err := rows.Scan(
+			&entry.MigrationId,
+			&entry.Action,
+			&entry.Checksum,
+			&startedAt,
+			&finishedAt,
+			&durationMs,
+			&entry.AppliedBy,
+			&entry.Host,
+			&entry.Version,
+			&entry.Note,
+		); err != nil {
+			return nil, err
+		}
+		entry.StartedAt = time.Unix(startedAt, 0)
+		entry.FinishedAt = time.Unix(finishedAt, 0)
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		results = append(results, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -3,12 +3,18 @@ package sqlite
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/easynow112/dbkit/config"
 )
 
+const defaultLockExpiry = 5 * time.Minute
+
 type Config struct {
-	DSN string
+	DSN           string
+	Table         string
+	LockExpiry    time.Duration
+	LockHeartbeat time.Duration
 }
 
 func newConfig(portConfig *config.DriverConfig, baseDir string) (*Config, error) {
@@ -17,11 +23,31 @@ func newConfig(portConfig *config.DriverConfig, baseDir string) (*Config, error)
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := rawConfig["schema"]; ok {
+		return nil, fmt.Errorf("sqlite driver does not support 'schema' in config, use 'table' to separate projects sharing a database")
+	}
 	if !filepath.IsAbs(path) {
 		path = filepath.Join(baseDir, filepath.ToSlash(path))
 	}
+	lockExpiry, err := configDuration(rawConfig, "lockExpiry", defaultLockExpiry)
+	if err != nil {
+		return nil, err
+	}
+	if lockExpiry < time.Second {
+		return nil, fmt.Errorf("sqlite driver expects 'lockExpiry' to be at least 1s, received: %s", rawConfig["lockExpiry"])
+	}
+	lockHeartbeat, err := configDuration(rawConfig, "lockHeartbeat", lockExpiry/3)
+	if err != nil {
+		return nil, err
+	}
+	if lockHeartbeat <= 0 || lockHeartbeat >= lockExpiry {
+		return nil, fmt.Errorf("sqlite driver expects 'lockHeartbeat' to be positive and shorter than 'lockExpiry' (%s), received: %s", lockExpiry, rawConfig["lockHeartbeat"])
+	}
 	return &Config{
-		DSN: fmt.Sprintf("file:%s", path),
+		DSN:           fmt.Sprintf("file:%s", path),
+		Table:         rawConfig["table"],
+		LockExpiry:    lockExpiry,
+		LockHeartbeat: lockHeartbeat,
 	}, nil
 }
 
@@ -32,3 +58,15 @@ func configString(rawConfig map[string]string, key string) (string, error) {
 	}
 	return value, nil
 }
+
+func configDuration(rawConfig map[string]string, key string, defaultValue time.Duration) (time.Duration, error) {
+	strVal, ok := rawConfig[key]
+	if !ok || strVal == "" {
+		return defaultValue, nil
+	}
+	parsed, err := time.ParseDuration(strVal)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite driver expects '%s' to be a duration such as 30s or 5m, received: %s", key, strVal)
+	}
+	return parsed, nil
+}
@@ -3,11 +3,16 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/easynow112/dbkit/db"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 type Connection struct {
@@ -29,33 +34,40 @@ func (c *Connection) TryAcquireLock(ctx context.Context) (db.Lock, error) {
 
 	rollback := func(e error) (db.Lock, error) {
 		_ = tx.Rollback()
+		if isBusy(e) {
+			return nil, db.ErrLockHeld
+		}
 		return nil, e
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		CREATE TABLE IF NOT EXISTS migration_lock (
-		id INTEGER PRIMARY KEY CHECK (id = 0),
-		locked BOOLEAN NOT NULL,
-		owner INTEGER,
-		lock_expires INTEGER
-		);`)
-	if err != nil {
-		return rollback(fmt.Errorf("failed to create migration_lock table: %w", err))
+	// The lock table is part of the versioned dbkit schema, so it is only
+	// created or upgraded here when the database is behind
+	schemaStore := &AppliedMigrationStore{
+		querier: tx,
+		tables:  c.db.tables,
+	}
+	if err := schemaStore.upgradeSchema(ctx); err != nil {
+		return rollback(err)
 	}
 
-	_, err = tx.ExecContext(ctx, `
-		INSERT OR IGNORE INTO migration_lock (id, owner, locked, lock_expires)
+	_, err = tx.ExecContext(ctx, c.db.tables.expand(`
+		INSERT OR IGNORE INTO {lock} (id, owner, locked, lock_expires)
 		VALUES (0, NULL, 0, NULL)
-	`)
+	`))
 	if err != nil {
 		return rollback(fmt.Errorf("failed to initialize migration lock row: %w", err))
 	}
 
 	ownerId := time.Now().UnixNano()
+	owner := db.CurrentLockOwner()
 
 	now := time.Now().Unix()
-	lockExpires := now + (5 * 60)
-	res, err := tx.ExecContext(ctx, "UPDATE migration_lock SET locked = 1, lock_expires = ?, owner = ? WHERE id = 0 AND (locked = 0 OR lock_expires < ?)", lockExpires, ownerId, now)
+	lockExpires := time.Now().Add(c.db.config.LockExpiry).Unix()
+	res, err := tx.ExecContext(ctx, c.db.tables.expand(`
+		UPDATE {lock}
+		SET locked = 1, lock_expires = ?, owner = ?, owner_user = ?, owner_host = ?, owner_pid = ?, locked_at = ?
+		WHERE id = 0 AND (locked = 0 OR lock_expires < ?)
+	`), lockExpires, ownerId, owner.User, owner.Host, owner.Pid, now, now)
 	if err != nil {
 		return rollback(fmt.Errorf("failed to acquire migration lock: %w", err))
 	}
@@ -65,17 +77,117 @@ func (c *Connection) TryAcquireLock(ctx context.Context) (db.Lock, error) {
 		return rollback(fmt.Errorf("failed to check lock acquisition: %w", err))
 	}
 	if rows == 0 {
-		return rollback(fmt.Errorf("another migration process is already running"))
+		return rollback(db.ErrLockHeld)
 	}
 
 	if err := tx.Commit(); err != nil {
+		if isBusy(err) {
+			return nil, db.ErrLockHeld
+		}
 		return nil, fmt.Errorf("failed to commit lock acquisition: %w", err)
 	}
 
-	return &Lock{
-		id:   ownerId,
-		conn: c.conn,
-	}, nil
+	return newLock(ownerId, c), nil
+}
+
+func (c *Connection) LockStatus(ctx context.Context) (db.LockStatus, error) {
+	if c.closed.Load() {
+		return db.LockStatus{}, fmt.Errorf("connection is closed")
+	}
+	// Status checks must not write, so lock tables created before the owner
+	// columns existed are read as they are instead of being upgraded.
+	columns, err := c.lockColumns(ctx)
+	if err != nil {
+		return db.LockStatus{}, err
+	}
+	if len(columns) == 0 {
+		return db.LockStatus{}, nil
+	}
+	selected := []string{"locked"}
+	for _, column := range lockOwnerColumns {
+		if columns[column[0]] {
+			selected = append(selected, column[0])
+		} else {
+			selected = append(selected, "NULL AS "+column[0])
+		}
+	}
+	selected = append(selected, "lock_expires")
+	var locked bool
+	var user, host sql.NullString
+	var pid, lockedAt, lockExpires sql.NullInt64
+	err = c.conn.QueryRowContext(ctx, c.db.tables.expand(fmt.Sprintf(`
+		SELECT %s
+		FROM {lock}
+		WHERE id = 0
+	`, strings.Join(selected, ", ")))).Scan(&locked, &user, &host, &pid, &lockedAt, &lockExpires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.LockStatus{}, nil
+	}
+	if err != nil {
+		return db.LockStatus{}, fmt.Errorf("failed to read migration lock: %w", err)
+	}
+	if !locked {
+		return db.LockStatus{}, nil
+	}
+	status := db.LockStatus{
+		Owner: db.LockOwner{
+			User: user.String,
+			Host: host.String,
+			Pid:  int(pid.Int64),
+		},
+	}
+	if lockedAt.Valid {
+		since := time.Unix(lockedAt.Int64, 0)
+		status.Since = &since
+	}
+	if lockExpires.Valid {
+		expires := time.Unix(lockExpires.Int64, 0)
+		status.Expires = &expires
+		status.Held = expires.After(time.Now())
+	}
+	return status, nil
+}
+
+func (c *Connection) lockColumns(ctx context.Context) (map[string]bool, error) {
+	rows, err := c.conn.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, c.db.tables.Lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s table: %w", c.db.tables.Lock, err)
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to inspect %s table: %w", c.db.tables.Lock, err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to inspect %s table: %w", c.db.tables.Lock, err)
+	}
+	return columns, nil
+}
+
+func (c *Connection) ForceReleaseLock(ctx context.Context, _ bool) (db.LockStatus, error) {
+	status, err := c.LockStatus(ctx)
+	if err != nil {
+		return status, err
+	}
+	if status.Held || status.Expires != nil {
+		_, err = c.conn.ExecContext(ctx, c.db.tables.expand(`
+			UPDATE {lock}
+			SET locked = 0, lock_expires = NULL, owner = NULL
+			WHERE id = 0
+		`))
+		if err != nil {
+			return status, fmt.Errorf("failed to release migration lock: %w", err)
+		}
+	}
+	return status, nil
+}
+
+func (c *Connection) AcquireLock(ctx context.Context, timeout time.Duration) (db.Lock, error) {
+	return db.WaitForLock(ctx, timeout, c.TryAcquireLock)
 }
 
 func (c *Connection) Close() error {
@@ -94,7 +206,8 @@ func (c *Connection) Close() error {
 
 func (c *Connection) AppliedMigrationStore() db.AppliedMigrationStore {
 	return &AppliedMigrationStore{
-		conn: c.conn,
+		querier: c.conn,
+		tables:  c.db.tables,
 	}
 }
 
@@ -116,6 +229,7 @@ func (c *Connection) BeginTrx(ctx context.Context) (trx db.Transaction, err erro
 
 	tx, err := c.conn.BeginTx(ctx, nil)
 	if err != nil {
+		c.trxInProgress.Store(false)
 		return nil, err
 	}
 	return &Transaction{
@@ -123,3 +237,13 @@ func (c *Connection) BeginTrx(ctx context.Context) (trx db.Transaction, err erro
 		conn: c,
 	}, nil
 }
+
+func (c *Connection) rollbackInterrupted(ctx context.Context) error {
+	_, err := c.conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
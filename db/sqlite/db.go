@@ -14,6 +14,8 @@ type DB struct {
 	mu          sync.Mutex
 	connections int
 	sqlDB       *sql.DB
+	tables      *tables
+	config      *Config
 }
 
 func (db *DB) AcquireConnection(ctx context.Context) (db.Connection, error) {
@@ -52,12 +54,19 @@ func NewDB(ctx context.Context, driverCfg *config.DriverConfig, globalConfig *co
 		return nil, err
 	}
 
+	tables, err := newTables(cfg.Table)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite driver: %w", err)
+	}
+
 	sqlDB, err := sql.Open("sqlite", cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DB{
-		sqlDB: sqlDB,
+		sqlDB:  sqlDB,
+		tables: tables,
+		config: cfg,
 	}, nil
 }
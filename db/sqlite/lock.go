@@ -2,17 +2,104 @@ package sqlite
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"sync"
+	"time"
 )
 
+// Lock is a lease on the lock table row renewed by a heartbeat. Renewals run
+// on a separate pooled connection so they commit on their own instead of
+// joining a migration transaction on the lock's connection. SQLite allows a
+// single writer, so renewals fail while a migration holds the write lock and
+// the lock is reported lost once the lease could expire; 'lockExpiry' must
+// outlast the longest migration.
 type Lock struct {
-	id   int64
-	conn *sql.Conn
+	id       int64
+	conn     *Connection
+	stop     chan struct{}
+	stopped  chan struct{}
+	lost     chan struct{}
+	stopOnce sync.Once
+}
+
+func newLock(id int64, conn *Connection) *Lock {
+	lock := &Lock{
+		id:      id,
+		conn:    conn,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+	go lock.heartbeat()
+	return lock
+}
+
+func (lock *Lock) heartbeat() {
+	defer close(lock.stopped)
+	config := lock.conn.db.config
+	ticker := time.NewTicker(config.LockHeartbeat)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+		}
+		if lock.conn.closed.Load() {
+			return
+		}
+		held, err := lock.renew()
+		if err != nil {
+			// Failures are retried on the next tick until the lease would expire
+			// before another renewal could run.
+			if time.Since(renewedAt)+config.LockHeartbeat >= config.LockExpiry {
+				close(lock.lost)
+				return
+			}
+			continue
+		}
+		renewedAt = time.Now()
+		if !held {
+			close(lock.lost)
+			return
+		}
+	}
+}
+
+func (lock *Lock) renew() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lock.conn.db.config.LockHeartbeat)
+	defer cancel()
+	lockExpires := time.Now().Add(lock.conn.db.config.LockExpiry).Unix()
+	res, err := lock.conn.db.sqlDB.ExecContext(ctx, lock.conn.db.tables.expand(`
+		UPDATE {lock}
+		SET lock_expires = ?
+		WHERE id = 0 AND locked = 1 AND owner = ?
+	`), lockExpires, lock.id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (lock *Lock) stopHeartbeat() {
+	lock.stopOnce.Do(func() {
+		close(lock.stop)
+	})
+	<-lock.stopped
+}
+
+func (lock *Lock) Lost() <-chan struct{} {
+	return lock.lost
 }
 
 func (lock *Lock) Release(ctx context.Context) error {
-	tx, err := lock.conn.BeginTx(ctx, nil)
+	lock.stopHeartbeat()
+	tx, err := lock.conn.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -20,13 +107,13 @@ func (lock *Lock) Release(ctx context.Context) error {
 		_ = tx.Rollback()
 		return e
 	}
-	res, err := tx.ExecContext(ctx, `
-		UPDATE migration_lock
+	res, err := tx.ExecContext(ctx, lock.conn.db.tables.expand(`
+		UPDATE {lock}
 		SET locked = 0,
 		    lock_expires = NULL,
 			owner = NULL
 		WHERE id = 0 AND locked = 1 AND owner = ?
-	`, lock.id)
+	`), lock.id)
 	if err != nil {
 		return rollback(fmt.Errorf("failed to release migration lock: %w", err))
 	}
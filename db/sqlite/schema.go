@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type schemaUpgrade func(ctx context.Context, store *AppliedMigrationStore) error
+
+var schemaUpgrades = []schemaUpgrade{
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {migrations} (
+			id TEXT PRIMARY KEY,
+			checksum TEXT,
+			started_at INTEGER NOT NULL,
+			finished_at INTEGER,
+			rollback_started_at INTEGER
+		);
+	`),
+	addColumns(migrationsTable, [][2]string{
+		{"duration_ms", "INTEGER"},
+		{"applied_by", "TEXT"},
+		{"host", "TEXT"},
+		{"dbkit_version", "TEXT"},
+		{"note", "TEXT"},
+	}),
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {history} (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			migration_id TEXT NOT NULL,
+			action TEXT NOT NULL,
+			checksum TEXT,
+			started_at INTEGER NOT NULL,
+			finished_at INTEGER NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			applied_by TEXT,
+			host TEXT,
+			dbkit_version TEXT,
+			note TEXT
+		);
+	`),
+	execStatements(`
+		CREATE TABLE IF NOT EXISTS {lock} (
+			id INTEGER PRIMARY KEY CHECK (id = 0),
+			locked BOOLEAN NOT NULL,
+			owner INTEGER,
+			lock_expires INTEGER
+		);
+	`),
+	addColumns(lockTable, lockOwnerColumns),
+}
+
+var lockOwnerColumns = [][2]string{
+	{"owner_user", "TEXT"},
+	{"owner_host", "TEXT"},
+	{"owner_pid", "INTEGER"},
+	{"locked_at", "INTEGER"},
+}
+
+const (
+	schemaVersionMigrations = 1
+	schemaVersionRunInfo    = 2
+	schemaVersionHistory    = 3
+)
+
+func execStatements(statements ...string) schemaUpgrade {
+	return func(ctx context.Context, store *AppliedMigrationStore) error {
+		for _, statement := range statements {
+			if _, err := store.exec(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func migrationsTable(tables *tables) string {
+	return tables.Migrations
+}
+
+func lockTable(tables *tables) string {
+	return tables.Lock
+}
+
+func addColumns(table func(tables *tables) string, columns [][2]string) schemaUpgrade {
+	return func(ctx context.Context, store *AppliedMigrationStore) error {
+		name := table(store.tables)
+		for _, column := range columns {
+			column, definition := column[0], column[1]
+			var count int
+			err := store.querier.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, name, column).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if _, err := store.querier.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, quote(name), column, definition)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (store *AppliedMigrationStore) upgradeSchema(ctx context.Context) error {
+	version, err := store.schemaVersion(ctx)
+	if err != nil || version >= len(schemaUpgrades) {
+		return err
+	}
+	_, err = store.exec(ctx, `
+		CREATE TABLE IF NOT EXISTS {meta} (
+			id INTEGER PRIMARY KEY CHECK (id = 0),
+			schema_version INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", store.tables.Meta, err)
+	}
+	for ; version < len(schemaUpgrades); version++ {
+		if err := store.applySchemaUpgrade(ctx, version+1); err != nil {
+			return fmt.Errorf("failed to upgrade dbkit schema to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+type beginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+func (store *AppliedMigrationStore) applySchemaUpgrade(ctx context.Context, version int) error {
+	conn, ok := store.querier.(beginner)
+	if !ok {
+		return store.runSchemaUpgrade(ctx, version)
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	txStore := &AppliedMigrationStore{
+		querier: tx,
+		tables:  store.tables,
+	}
+	if err := txStore.runSchemaUpgrade(ctx, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *AppliedMigrationStore) runSchemaUpgrade(ctx context.Context, version int) error {
+	if err := schemaUpgrades[version-1](ctx, store); err != nil {
+		return err
+	}
+	_, err := store.exec(ctx, `
+		INSERT INTO {meta} (id, schema_version) VALUES (0, ?)
+		ON CONFLICT (id) DO UPDATE SET schema_version = excluded.schema_version
+	`, version)
+	return err
+}
+
+func (store *AppliedMigrationStore) schemaVersion(ctx context.Context) (int, error) {
+	var metaExists, migrationsExists bool
+	err := store.querier.QueryRowContext(ctx, `
+		SELECT
+			EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?),
+			EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)
+	`, store.tables.Meta, store.tables.Migrations).Scan(&metaExists, &migrationsExists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check dbkit schema: %w", err)
+	}
+	if metaExists {
+		var version int
+		err := store.queryRow(ctx, `SELECT COALESCE(MAX(schema_version), 0) FROM {meta}`).Scan(&version)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read dbkit schema version: %w", err)
+		}
+		if version > 0 {
+			return version, nil
+		}
+	}
+	if migrationsExists {
+		return schemaVersionMigrations, nil
+	}
+	return 0, nil
+}
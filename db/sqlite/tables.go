@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+type tables struct {
+	db.Tables
+	replacer *strings.Replacer
+}
+
+func newTables(table string) (*tables, error) {
+	names, err := db.NewTables(table)
+	if err != nil {
+		return nil, err
+	}
+	return &tables{
+		Tables: names,
+		replacer: strings.NewReplacer(
+			"{migrations}", quote(names.Migrations),
+			"{history}", quote(names.History),
+			"{meta}", quote(names.Meta),
+			"{lock}", quote(names.Lock),
+		),
+	}, nil
+}
+
+func quote(identifier string) string {
+	return `"` + identifier + `"`
+}
+
+func (t *tables) expand(query string) string {
+	return t.replacer.Replace(query)
+}
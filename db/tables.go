@@ -0,0 +1,44 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const DefaultTable = "migrations"
+
+var validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+type Tables struct {
+	Migrations string
+	History    string
+	Meta       string
+	Lock       string
+}
+
+func NewTables(table string) (Tables, error) {
+	if table == "" || table == DefaultTable {
+		return Tables{
+			Migrations: DefaultTable,
+			History:    "migration_history",
+			Meta:       "dbkit_meta",
+			Lock:       "migration_lock",
+		}, nil
+	}
+	if err := ValidateIdentifier("table", table); err != nil {
+		return Tables{}, err
+	}
+	return Tables{
+		Migrations: table,
+		History:    table + "_history",
+		Meta:       table + "_meta",
+		Lock:       table + "_lock",
+	}, nil
+}
+
+func ValidateIdentifier(key string, value string) error {
+	if !validIdentifier.MatchString(value) {
+		return fmt.Errorf("invalid %s '%s', expected letters, digits and underscores starting with a letter or underscore", key, value)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package db_test
+
+import (
+	"maps"
+	"testing"
+)
+
+func defaultTablesCase(c driverCase) driverCase {
+	c.config.Config = maps.Clone(c.config.Config)
+	delete(c.config.Config, "table")
+	delete(c.config.Config, "schema")
+	c.tables = nil
+	return c
+}
+
+func TestCustomTables(t *testing.T) {
+	driverCases := getDriverCases()
+	for _, driverCase := range driverCases {
+		if len(driverCase.tables) == 0 {
+			continue
+		}
+		t.Run(driverCase.name(), func(t *testing.T) {
+
+			t.Run("schema and lock tables are created under the custom names", func(t *testing.T) {
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				appliedMigrationStore(t, conn)
+				acquireLock(t, conn)
+				for _, table := range driverCase.tables {
+					if err := conn.Exec(t.Context(), "SELECT COUNT(*) FROM "+table); err != nil {
+						t.Fatalf("expected %s to exist: %v", table, err)
+					}
+				}
+			})
+
+			t.Run("applied migrations are listed from the custom tables only", func(t *testing.T) {
+				customStore := appliedMigrationStore(t, acquireConnection(t, initDB(t, driverCase)))
+				defaultStore := appliedMigrationStore(t, acquireConnection(t, initDB(t, defaultTablesCase(driverCase))))
+				id := recordStarted(t, customStore)
+				custom, err := customStore.List(t.Context())
+				if err != nil {
+					t.Fatalf("failed to list custom migrations: %v", err)
+				}
+				if !containsId(custom, id) {
+					t.Fatalf("expected %s to be listed from the custom tables", id)
+				}
+				defaults, err := defaultStore.List(t.Context())
+				if err != nil {
+					t.Fatalf("failed to list default migrations: %v", err)
+				}
+				if containsId(defaults, id) {
+					t.Fatalf("expected %s not to be listed from the default tables", id)
+				}
+			})
+
+			t.Run("the custom lock is independent of the default lock", func(t *testing.T) {
+				defaultConn := acquireConnection(t, initDB(t, defaultTablesCase(driverCase)))
+				customConn := acquireConnection(t, initDB(t, driverCase))
+				acquireLock(t, defaultConn)
+				acquireLock(t, customConn)
+				status, err := customConn.LockStatus(t.Context())
+				if err != nil {
+					t.Fatalf("failed to get lock status: %v", err)
+				}
+				if !status.Held {
+					t.Fatalf("expected the custom lock to be held, got %+v", status)
+				}
+			})
+		})
+	}
+}
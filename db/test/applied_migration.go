@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -11,8 +12,9 @@ import (
 )
 
 type AppliedMigrationStore struct {
-	mu   sync.Mutex
-	rows map[string]*db.AppliedMigration
+	mu      sync.Mutex
+	rows    map[string]*db.AppliedMigration
+	history []db.HistoryEntry
 }
 
 func (store *AppliedMigrationStore) EnsureSchema(ctx context.Context) error {
@@ -22,6 +24,20 @@ func (store *AppliedMigrationStore) EnsureSchema(ctx context.Context) error {
 	return nil
 }
 
+func (store *AppliedMigrationStore) SchemaExists(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return true, nil
+}
+
+func (store *AppliedMigrationStore) SchemaVersion(ctx context.Context) (int, int, error) {
+	if ctx.Err() != nil {
+		return 0, 0, ctx.Err()
+	}
+	return 1, 1, nil
+}
+
 func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigration, error) {
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
@@ -38,6 +54,20 @@ func (store *AppliedMigrationStore) List(ctx context.Context) ([]db.AppliedMigra
 	return results, nil
 }
 
+func (store *AppliedMigrationStore) ListDirty(ctx context.Context) ([]db.AppliedMigration, error) {
+	all, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]db.AppliedMigration, 0)
+	for _, row := range all {
+		if row.FinishedAt == nil || row.RollbackStartedAt != nil {
+			results = append(results, row)
+		}
+	}
+	return results, nil
+}
+
 func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -51,7 +81,25 @@ func (store *AppliedMigrationStore) Remove(ctx context.Context, id string) error
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string) error {
+func (store *AppliedMigrationStore) MarkApplied(ctx context.Context, id string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	row, ok := store.rows[id]
+	if !ok {
+		return fmt.Errorf("migration %s not found", id)
+	}
+	if row.FinishedAt == nil {
+		now := time.Now()
+		row.FinishedAt = &now
+	}
+	row.RollbackStartedAt = nil
+	return nil
+}
+
+func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string, checksum string, info db.RunInfo) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
@@ -66,11 +114,12 @@ func (store *AppliedMigrationStore) RecordStarted(ctx context.Context, id string
 		StartedAt:         time.Now(),
 		FinishedAt:        nil,
 		RollbackStartedAt: nil,
+		RunInfo:           info,
 	}
 	return nil
 }
 
-func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string) error {
+func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id string, duration time.Duration) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
@@ -81,6 +130,7 @@ func (store *AppliedMigrationStore) RecordFinished(ctx context.Context, id strin
 	}
 	now := time.Now()
 	store.rows[id].FinishedAt = &now
+	store.rows[id].Duration = &duration
 	return nil
 }
 
@@ -98,6 +148,25 @@ func (store *AppliedMigrationStore) RecordRollbackStarted(ctx context.Context, i
 	return nil
 }
 
+func (store *AppliedMigrationStore) RecordHistory(ctx context.Context, entry db.HistoryEntry) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.history = append(store.history, entry)
+	return nil
+}
+
+func (store *AppliedMigrationStore) History(ctx context.Context) ([]db.HistoryEntry, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return slices.Clone(store.history), nil
+}
+
 func NewStore(rows map[string]*db.AppliedMigration) *AppliedMigrationStore {
 	return &AppliedMigrationStore{
 		rows: rows,
@@ -3,7 +3,9 @@ package test
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/easynow112/dbkit/db"
 )
@@ -39,10 +41,11 @@ func (conn *Connection) TryAcquireLock(ctx context.Context) (db.Lock, error) {
 	case nil:
 		ls.owner = conn
 		ls.count = 1
+		ls.since = time.Now()
 	case conn:
 		ls.count++
 	default:
-		return nil, fmt.Errorf("lock already held")
+		return nil, db.ErrLockHeld
 	}
 	return &Lock{
 		state: &ls,
@@ -50,6 +53,52 @@ func (conn *Connection) TryAcquireLock(ctx context.Context) (db.Lock, error) {
 	}, nil
 }
 
+func (conn *Connection) AcquireLock(ctx context.Context, timeout time.Duration) (db.Lock, error) {
+	return db.WaitForLock(ctx, timeout, conn.TryAcquireLock)
+}
+
+func (conn *Connection) LockStatus(ctx context.Context) (db.LockStatus, error) {
+	if ctx.Err() != nil {
+		return db.LockStatus{}, ctx.Err()
+	}
+	if err := conn.ensureOpen(); err != nil {
+		return db.LockStatus{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return lockStatus(), nil
+}
+
+func lockStatus() db.LockStatus {
+	if ls.owner == nil {
+		return db.LockStatus{}
+	}
+	since := ls.since
+	return db.LockStatus{
+		Held:  true,
+		Owner: db.CurrentLockOwner(),
+		Since: &since,
+	}
+}
+
+func (conn *Connection) ForceReleaseLock(ctx context.Context, terminate bool) (db.LockStatus, error) {
+	if ctx.Err() != nil {
+		return db.LockStatus{}, ctx.Err()
+	}
+	if err := conn.ensureOpen(); err != nil {
+		return db.LockStatus{}, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	status := lockStatus()
+	if ls.owner != nil && ls.owner != conn && !terminate {
+		return status, db.ErrTerminateRequired
+	}
+	ls.owner = nil
+	ls.count = 0
+	return status, nil
+}
+
 func (conn *Connection) Close() error {
 	swapped := conn.closed.CompareAndSwap(false, true)
 	if !swapped {
@@ -80,8 +129,16 @@ func (conn *Connection) Exec(ctx context.Context, query string, args ...any) err
 	if err := conn.ensureOpen(); err != nil {
 		return err
 	}
-	if query == "INVALID_QUERY" {
+	return exec(ctx, query)
+}
+
+func exec(ctx context.Context, query string) error {
+	switch {
+	case strings.Contains(query, "INVALID_QUERY"):
 		return fmt.Errorf("query is invalid")
+	case query == "SLEEP":
+		<-ctx.Done()
+		return ctx.Err()
 	}
 	return nil
 }
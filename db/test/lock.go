@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 type lockState struct {
 	mu    sync.Mutex
 	owner *Connection
 	count int
+	since time.Time
 }
 
 var ls = lockState{
@@ -42,3 +44,7 @@ func (lock *Lock) Release(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (lock *Lock) Lost() <-chan struct{} {
+	return nil
+}
@@ -3,35 +3,39 @@ package test
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+
+	"github.com/easynow112/dbkit/db"
 )
 
 type Transaction struct {
 	conn *Connection
+	done atomic.Bool
 }
 
 func (trx *Transaction) Commit(ctx context.Context) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-	if ok := trx.conn.trxInProg.CompareAndSwap(true, false); !ok {
-		return fmt.Errorf("no transaction is in progress")
-	}
-	return nil
+	return trx.end(ctx)
 }
 
 func (trx *Transaction) Rollback(ctx context.Context) error {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-	if ok := trx.conn.trxInProg.CompareAndSwap(true, false); !ok {
+	return trx.end(ctx)
+}
+
+func (trx *Transaction) end(ctx context.Context) error {
+	if trx.done.Swap(true) {
 		return fmt.Errorf("no transaction is in progress")
 	}
-	return nil
+	trx.conn.trxInProg.Store(false)
+	return ctx.Err()
 }
 
 func (trx *Transaction) Exec(ctx context.Context, query string, args ...any) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	return nil
+	return exec(ctx, query)
+}
+
+func (trx *Transaction) AppliedMigrationStore() db.AppliedMigrationStore {
+	return trx.conn.db.store
 }
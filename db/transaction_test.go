@@ -2,7 +2,10 @@ package db_test
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/easynow112/dbkit/db"
 )
@@ -19,7 +22,7 @@ func beginTrx(t *testing.T, conn db.Connection) db.Transaction {
 func TestTransaction(t *testing.T) {
 	driverCases := getDriverCases()
 	for _, driverCase := range driverCases {
-		t.Run(driverCase.config.Driver, func(t *testing.T) {
+		t.Run(driverCase.name(), func(t *testing.T) {
 
 			t.Run("committing a transaction succeeds", func(t *testing.T) {
 				t.Parallel()
@@ -109,6 +112,55 @@ func TestTransaction(t *testing.T) {
 				}
 			})
 
+			for _, end := range []string{"commit", "rollback"} {
+				t.Run("a transaction cancelled during a statement frees the connection after "+end, func(t *testing.T) {
+					t.Parallel()
+					pool := initDB(t, driverCase)
+					conn := acquireConnection(t, pool)
+					ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+					defer cancel()
+					trx, err := conn.BeginTrx(ctx)
+					if err != nil {
+						t.Fatalf("failed to begin transaction: %v", err)
+					}
+					if err := trx.Exec(ctx, driverCase.sleepQuery); err == nil {
+						t.Fatalf("expected the statement to be interrupted by the context")
+					}
+					if end == "commit" {
+						if err := trx.Commit(ctx); err == nil {
+							t.Fatalf("expected commit to fail after the context was cancelled")
+						}
+					} else {
+						trx.Rollback(context.WithoutCancel(ctx))
+					}
+					if driverCase.closesOnCancel {
+						if _, err := conn.BeginTrx(t.Context()); !errors.Is(err, driver.ErrBadConn) {
+							t.Fatalf("expected the interrupted connection to be discarded, got %v", err)
+						}
+						return
+					}
+					trx2 := beginTrx(t, conn)
+					if err := trx2.Exec(t.Context(), "SELECT 1"); err != nil {
+						t.Fatalf("failed to use replacement transaction: %v", err)
+					}
+					if err := trx2.Rollback(t.Context()); err != nil {
+						t.Fatalf("failed to rollback replacement transaction: %v", err)
+					}
+				})
+			}
+
+			t.Run("transactions return non-nil applied migration store", func(t *testing.T) {
+				t.Parallel()
+				pool := initDB(t, driverCase)
+				conn := acquireConnection(t, pool)
+				trx := beginTrx(t, conn)
+				t.Cleanup(func() { trx.Rollback(context.Background()) })
+				store := trx.AppliedMigrationStore()
+				if store == nil {
+					t.Fatalf("expected non-nil applied migration store")
+				}
+			})
+
 			t.Run("attempting to start multiple transactions on the same connection fails", func(t *testing.T) {
 				t.Parallel()
 				ctx := t.Context()
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/db"
+)
+
+const (
+	exitFailure    = 1
+	exitUsage      = 2
+	exitLock       = 3
+	exitValidation = 4
+	exitSQL        = 5
+)
+
+func exitCode(err error) int {
+	var errInvalidArgs *apperrors.InvalidArgs
+	var errValidation *apperrors.Validation
+	var errSQL *apperrors.SQLFailure
+	switch {
+	case errors.As(err, &errInvalidArgs):
+		return exitUsage
+	case errors.Is(err, db.ErrLockHeld), errors.Is(err, db.ErrLockLost), errors.Is(err, db.ErrTerminateRequired):
+		return exitLock
+	case errors.As(err, &errValidation):
+		return exitValidation
+	case errors.As(err, &errSQL):
+		return exitSQL
+	}
+	return exitFailure
+}
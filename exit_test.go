@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/db/test"
+	"github.com/easynow112/dbkit/source"
+	"github.com/easynow112/dbkit/source/sourcetest"
+)
+
+func combinedMigration(up string) string {
+	return fmt.Sprintf("-- +dbkit Up\n%s\n-- +dbkit Down\nSELECT 1;\n", up)
+}
+
+func testDB(store db.AppliedMigrationStore) db.DBFactory {
+	return func(ctx context.Context, cfg *config.Config, target string) (db.DB, error) {
+		return test.NewFactory(store)(ctx, &config.DriverConfig{Driver: "test"}, &config.GlobalConfig{})
+	}
+}
+
+func holdLock(t *testing.T) {
+	t.Helper()
+	database, err := testDB(test.NewStore(map[string]*db.AppliedMigration{}))(t.Context(), nil, "main")
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	conn, err := database.AcquireConnection(t.Context())
+	if err != nil {
+		t.Fatalf("failed to acquire connection: %v", err)
+	}
+	lock, err := conn.TryAcquireLock(t.Context())
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	t.Cleanup(func() {
+		lock.Release(context.Background())
+		conn.Close()
+		database.Close()
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	finishedAt := time.Now()
+	cases := []struct {
+		name       string
+		args       []string
+		config     config.ConfigFactory
+		migrations map[string]string
+		applied    map[string]*db.AppliedMigration
+		lockHeld   bool
+		want       int
+	}{
+		{
+			name: "unknown command",
+			args: []string{"dbkit", "bogus"},
+			want: exitUsage,
+		},
+		{
+			name: "invalid steps",
+			args: []string{"dbkit", "migrate", "up", "--steps", "none"},
+			want: exitUsage,
+		},
+		{
+			name: "invalid config",
+			args: []string{"dbkit", "migrate", "up"},
+			config: func(overrides config.Overrides) (*config.Config, error) {
+				return nil, fmt.Errorf("no dbkit.json found")
+			},
+			want: exitValidation,
+		},
+		{
+			name:       "lock held by another process",
+			args:       []string{"dbkit", "migrate", "up"},
+			migrations: map[string]string{"a": combinedMigration("SELECT 1;")},
+			lockHeld:   true,
+			want:       exitLock,
+		},
+		{
+			name:       "applied migration missing from source",
+			args:       []string{"dbkit", "migrate", "up"},
+			migrations: map[string]string{"b": combinedMigration("SELECT 1;")},
+			applied:    map[string]*db.AppliedMigration{"a": {Id: "a", Checksum: "a", StartedAt: finishedAt, FinishedAt: &finishedAt}},
+			want:       exitValidation,
+		},
+		{
+			name:       "failing migration",
+			args:       []string{"dbkit", "migrate", "up"},
+			migrations: map[string]string{"a": combinedMigration("INVALID_QUERY")},
+			want:       exitSQL,
+		},
+		{
+			name:       "successful migration",
+			args:       []string{"dbkit", "migrate", "up"},
+			migrations: map[string]string{"a": combinedMigration("SELECT 1;")},
+			want:       0,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configFactory := c.config
+			if configFactory == nil {
+				configFactory = testConfig
+			}
+			migrations := sourcetest.NewStore()
+			for id, contents := range c.migrations {
+				migrations.Create(t.Context(), id, contents)
+			}
+			applied := c.applied
+			if applied == nil {
+				applied = map[string]*db.AppliedMigration{}
+			}
+			if c.lockHeld {
+				holdLock(t)
+			}
+			err := run(c.args, discardOutput(), configFactory, sourceStores(map[string]source.Store{"migrations": migrations}), testDB(test.NewStore(applied)))
+			got := 0
+			if err != nil {
+				got = exitCode(err)
+			}
+			if got != c.want {
+				t.Fatalf("expected exit code %d, got %d: %v", c.want, got, err)
+			}
+		})
+	}
+}
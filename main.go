@@ -1,9 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strconv"
@@ -13,125 +16,283 @@ import (
 	"github.com/easynow112/dbkit/apperrors"
 	"github.com/easynow112/dbkit/config"
 	"github.com/easynow112/dbkit/db"
+	_ "github.com/easynow112/dbkit/db/mysql"
 	_ "github.com/easynow112/dbkit/db/pg"
 	_ "github.com/easynow112/dbkit/db/sqlite"
 	"github.com/easynow112/dbkit/migrations"
 	"github.com/easynow112/dbkit/msg"
-	"github.com/easynow112/dbkit/seeds"
 	"github.com/easynow112/dbkit/source"
 	_ "github.com/easynow112/dbkit/source/fs"
 )
 
 func main() {
-	fmt.Println()
-	err := run(os.Args, config.LoadConfig, source.NewStore, db.NewDB)
+	format, args, err := parseOutputFlag(os.Args)
 	if err != nil {
-		var errInvalidArgs *apperrors.InvalidArgs
-		if errors.As(err, &errInvalidArgs) {
-			fmt.Println(errInvalidArgs.Error())
-			fmt.Printf("Hint:\n%s\n", errInvalidArgs.Hint)
-		} else {
-			fmt.Println(err)
-		}
-		fmt.Println()
-		os.Exit(1)
+		fmt.Println(err)
+		os.Exit(exitUsage)
+	}
+	out, err := newOutput(format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitUsage)
+	}
+	out.begin()
+	err = run(args, out, config.LoadConfig, source.NewStore, db.NewDB)
+	if err != nil {
+		out.printError(err)
+		out.end()
+		os.Exit(exitCode(err))
 	}
-	fmt.Println()
+	out.end()
 	os.Exit(0)
 }
 
-func run(args []string, configFactory config.ConfigFactory, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
-	cfg, err := configFactory()
+func run(args []string, out *output, configFactory config.ConfigFactory, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	lockTimeoutFlag, lockTimeoutFound, args, err := extractDurationFlag(args, "lock-timeout")
 	if err != nil {
-		return fmt.Errorf("Failed to load %s config:\n%v", config.FilePath, err)
+		return err
+	}
+	timeoutFlag, timeoutFound, args, err := extractDurationFlag(args, "timeout")
+	if err != nil {
+		return err
+	}
+	var overrides config.Overrides
+	if overrides.ConfigPath, args, err = extractOverrideFlag(args, "config"); err != nil {
+		return err
+	}
+	if overrides.Database, args, err = extractOverrideFlag(args, "database"); err != nil {
+		return err
+	}
+	if overrides.Environment, args, err = extractOverrideFlag(args, "env"); err != nil {
+		return err
 	}
+	if overrides.Source, args, err = extractOverrideFlag(args, "source"); err != nil {
+		return err
+	}
+	help, args := extractHelpFlag(args)
 
-	if len(args) < 2 {
+	if len(args) > 1 && args[1] == "help" {
+		cmd := resolveCommand(args[1:])
+		out.printHelp(cmd.help)
+		return nil
+	}
+	cmd := resolveCommand(args)
+	if help {
+		out.printHelp(cmd.help)
+		return nil
+	}
+	if cmd.run == nil {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: cmd.help,
+		}
+	}
+	if cmd.noSource && overrides.Source != "" {
 		return &apperrors.InvalidArgs{
 			Args: args,
-			Hint: msg.Usage,
+			Hint: "--source only selects migration sources, seeds are read from active.source.seeds",
 		}
 	}
+	if cmd.noConfig {
+		return cmd.run(context.Background(), args, out, nil, sourceStoreFactory, dbFactory)
+	}
+
+	cfg, err := configFactory(overrides)
+	if err != nil {
+		return &apperrors.Validation{Err: fmt.Errorf("Failed to load config:\n%v", err)}
+	}
+	if lockTimeoutFound {
+		cfg.Settings.LockTimeout = lockTimeoutFlag
+	}
+	if timeoutFound {
+		cfg.Settings.Timeout = timeoutFlag
+	}
+	lockTimeout, err := config.ParseDuration(cfg.Settings.LockTimeout)
+	if err != nil {
+		return &apperrors.Validation{Err: fmt.Errorf("Invalid lock timeout: %v", err)}
+	}
+	timeout, err := cfg.Settings.CommandTimeout()
+	if err != nil {
+		return &apperrors.Validation{Err: fmt.Errorf("Invalid timeout: %v", err)}
+	}
 
 	ctxSig, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	ctx, cancel := context.WithTimeout(ctxSig, 30*time.Second)
-	defer cancel()
 	defer stop()
+	ctx, cancel := withTimeout(ctxSig, timeout, lockTimeout)
+	defer cancel()
 
-	switch args[1] {
-	case "migrate":
-		{
-			if len(args) < 3 {
-				return &apperrors.InvalidArgs{
-					Args: args,
-					Hint: msg.Usage,
-				}
-			}
-			switch args[2] {
-			case "new":
-				return handleMigrateNew(ctx, args, cfg, sourceStoreFactory)
-			case "up":
-				return handleMigrateUp(ctx, args, cfg, sourceStoreFactory, dbFactory)
-			case "down":
-				return handleMigrateDown(ctx, args, cfg, sourceStoreFactory, dbFactory)
-			}
-		}
-	case "seed":
-		{
-			if len(args) == 2 {
-				return handleSeed(ctx, cfg, sourceStoreFactory, dbFactory)
-			} else if len(args) == 4 && args[2] == "new" {
-				return handleSeedNew(ctx, args, cfg, sourceStoreFactory)
-			}
-		}
-	}
-	return &apperrors.InvalidArgs{
-		Args: args,
-		Hint: msg.Usage,
+	return cmd.run(ctx, args, out, cfg, sourceStoreFactory, dbFactory)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration, lockTimeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout+lockTimeout)
 }
 
-func handleMigrateNew(ctx context.Context, args []string, cfg *config.Config, sourceStoreFactory source.StoreFactory) error {
+func handleMigrateNew(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, _ db.DBFactory) error {
 	if len(args) != 4 {
 		return &apperrors.InvalidArgs{
 			Args: args,
-			Hint: msg.UsageMigrateNew,
+			Hint: msg.MigrateNew.Help(),
 		}
 	}
-	return migrations.New(ctx, args[3], cfg, sourceStoreFactory)
+	stores, err := migrationStores(ctx, cfg, sourceStoreFactory)
+	if err != nil {
+		return err
+	}
+	_, err = migrations.NewMigrator(nil, stores, migrations.WithReporter(out.reporter)).NewMigration(ctx, args[3])
+	return err
 }
 
-func handleMigrateUp(ctx context.Context, args []string, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
-	var steps *int = nil
-	if len(args) > 4 {
+func handleMigrateUp(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	opts, err := parseRunArgs(args, cfg, msg.MigrateUp.Help())
+	if err != nil {
+		return err
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	result, err := migrator.Up(ctx, opts)
+	return out.reportResult(result, err)
+}
+
+func handleMigrateDown(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	opts, err := parseRunArgs(args, cfg, msg.MigrateDown.Help())
+	if err != nil {
+		return err
+	}
+	if opts.Steps == 0 && opts.Target == "" {
+		opts.Steps = 1
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	result, err := migrator.Down(ctx, opts)
+	return out.reportResult(result, err)
+}
+
+func handleMigrateGoto(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	flags := flag.NewFlagSet("migrate goto", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var opts migrations.RunOptions
+	addRunFlags(flags, cfg, &opts)
+	addPlanFlags(flags, &opts.DryRun, &opts.PlanFile)
+	positional, err := parseFlags(flags, args[3:])
+	if err != nil || len(positional) != 1 {
 		return &apperrors.InvalidArgs{
 			Args: args,
-			Hint: msg.UsageMigrateUp,
+			Hint: msg.MigrateGoto.Help(),
 		}
-	} else if len(args) == 4 {
-		stepsInt, err := parseSteps(args[3])
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	result, err := migrator.Goto(ctx, positional[0], opts)
+	return out.reportResult(result, err)
+}
+
+func parseRunArgs(args []string, cfg *config.Config, usage string) (opts migrations.RunOptions, err error) {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var steps string
+	flags.StringVar(&opts.Target, "to", "", "")
+	flags.StringVar(&steps, "steps", "", "")
+	addRunFlags(flags, cfg, &opts)
+	addPlanFlags(flags, &opts.DryRun, &opts.PlanFile)
+	positional, err := parseFlags(flags, args[3:])
+	if steps != "" {
+		positional = append(positional, steps)
+	}
+	if err != nil || len(positional) > 1 || (opts.Target != "" && len(positional) == 1) {
+		return opts, &apperrors.InvalidArgs{
+			Args: args,
+			Hint: usage,
+		}
+	}
+	if len(positional) == 1 {
+		opts.Steps, err = parseSteps(positional[0])
 		if err != nil {
-			return &apperrors.InvalidArgs{
+			return opts, &apperrors.InvalidArgs{
 				Args: args,
 				Hint: err.Error(),
 			}
-		} else {
-			steps = &stepsInt
 		}
 	}
-	return migrations.Run(ctx, true, steps, cfg, sourceStoreFactory, dbFactory)
+	return opts, nil
+}
+
+func addRunFlags(flags *flag.FlagSet, cfg *config.Config, opts *migrations.RunOptions) {
+	flags.BoolVar(&opts.AllowOutOfOrder, "allow-out-of-order", cfg.Settings.AllowOutOfOrder, "")
+	flags.StringVar(&opts.Note, "note", "", "")
+}
+
+func addPlanFlags(flags *flag.FlagSet, dryRun *bool, planFile *string) {
+	flags.BoolVar(dryRun, "dry-run", false, "")
+	flags.StringVar(planFile, "plan-file", "", "")
+}
+
+func handleMigrateStatus(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	if len(args) != 3 {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.MigrateStatus.Help(),
+		}
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	out.printStatuses(statuses)
+	return nil
+}
+
+func handleMigrateHistory(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	if len(args) != 3 {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.MigrateHistory.Help(),
+		}
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	history, err := migrator.History(ctx)
+	if err != nil {
+		return err
+	}
+	out.printHistory(history)
+	return nil
 }
 
-func handleMigrateDown(ctx context.Context, args []string, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
-	var steps int = 1
-	var err error
-	if len(args) > 4 {
+func handleMigrateRepair(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	flags := flag.NewFlagSet("migrate repair", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	yes := flags.Bool("yes", false, "")
+	positional, err := parseFlags(flags, args[3:])
+	if err != nil || len(positional) > 1 || (*yes && len(positional) == 0) {
 		return &apperrors.InvalidArgs{
 			Args: args,
-			Hint: msg.UsageMigrateDown,
+			Hint: msg.MigrateRepair.Help(),
 		}
-	} else if len(args) == 4 {
-		steps, err = parseSteps(args[3])
+	}
+	var action migrations.RepairAction
+	if len(positional) == 1 {
+		action, err = migrations.ParseRepairAction(positional[0])
 		if err != nil {
 			return &apperrors.InvalidArgs{
 				Args: args,
@@ -139,21 +300,172 @@ func handleMigrateDown(ctx context.Context, args []string, cfg *config.Config, s
 			}
 		}
 	}
-	return migrations.Run(ctx, false, &steps, cfg, sourceStoreFactory, dbFactory)
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, migrationStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	reader := bufio.NewReader(os.Stdin)
+	results, err := migrator.Repair(ctx, func(status migrations.MigrationStatus) (migrations.RepairAction, error) {
+		out.printDirtyMigration(status)
+		if *yes {
+			return action, nil
+		}
+		return out.promptRepairAction(reader, status.Id, action)
+	})
+	out.printRepairResults(results, err)
+	return err
 }
 
-func handleSeedNew(ctx context.Context, args []string, cfg *config.Config, sourceStoreFactory source.StoreFactory) error {
+func handleLockStatus(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	if len(args) != 3 {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.LockStatus.Help(),
+		}
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, noStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	status, err := migrator.LockStatus(ctx)
+	if err != nil {
+		return err
+	}
+	out.printLockStatus(status)
+	return nil
+}
+
+func handleLockRelease(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	flags := flag.NewFlagSet("lock release", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	force := flags.Bool("force", false, "")
+	terminate := flags.Bool("terminate", false, "")
+	positional, err := parseFlags(flags, args[3:])
+	if err != nil || len(positional) != 0 || !*force {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.LockRelease.Help(),
+		}
+	}
+	migrator, closeDB, err := newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, noStores)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	status, err := migrator.ForceReleaseLock(ctx, *terminate)
+	if errors.Is(err, db.ErrTerminateRequired) {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: fmt.Sprintf("%v\nPass --terminate to end the session holding the lock", err),
+		}
+	}
+	if err != nil {
+		return err
+	}
+	out.printLockReleased(status)
+	return nil
+}
+
+func handleSeedNew(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, _ db.DBFactory) error {
 	if len(args) != 4 {
 		return &apperrors.InvalidArgs{
 			Args: args,
-			Hint: msg.UsageSeedNew,
+			Hint: msg.SeedNew.Help(),
+		}
+	}
+	stores, err := seedStore(ctx, cfg, sourceStoreFactory)
+	if err != nil {
+		return err
+	}
+	_, err = migrations.NewMigrator(nil, stores, migrations.WithReporter(out.reporter)).NewSeed(ctx, args[3])
+	return err
+}
+
+func handleSeed(ctx context.Context, args []string, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var opts migrations.SeedOptions
+	addPlanFlags(flags, &opts.DryRun, &opts.PlanFile)
+	positional, err := parseFlags(flags, args[2:])
+	if err != nil || len(positional) != 0 {
+		return &apperrors.InvalidArgs{
+			Args: args,
+			Hint: msg.Seed.Help(),
+		}
+	}
+	var migrator *migrations.Migrator
+	closeDB := func() {}
+	if opts.DryRun {
+		migrator, err = newOfflineMigrator(ctx, out, cfg, sourceStoreFactory, seedStore)
+	} else {
+		migrator, closeDB, err = newMigrator(ctx, out, cfg, sourceStoreFactory, dbFactory, seedStore)
+	}
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	result, err := migrator.Seed(ctx, opts)
+	return out.reportResult(result, err)
+}
+
+type storeLoader func(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory) (migrations.Option, error)
+
+func migrationStores(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory) (migrations.Option, error) {
+	if cfg.Active.Source.Migrations.Combined != "" {
+		store, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Migrations.Combined)
+		if err != nil {
+			return nil, err
 		}
+		return migrations.WithCombinedMigrationStore(store), nil
+	}
+	upStore, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Migrations.Up)
+	if err != nil {
+		return nil, err
+	}
+	downStore, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Migrations.Down)
+	if err != nil {
+		return nil, err
 	}
-	return seeds.New(ctx, args[3], cfg, sourceStoreFactory)
+	return migrations.WithMigrationStores(upStore, downStore), nil
 }
 
-func handleSeed(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
-	return seeds.Run(ctx, cfg, sourceStoreFactory, dbFactory)
+func seedStore(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory) (migrations.Option, error) {
+	store, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Seeds)
+	if err != nil {
+		return nil, err
+	}
+	return migrations.WithSeedStore(store), nil
+}
+
+func noStores(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory) (migrations.Option, error) {
+	return func(m *migrations.Migrator) {}, nil
+}
+
+func newMigrator(ctx context.Context, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory, loadStores storeLoader) (*migrations.Migrator, func(), error) {
+	stores, err := loadStores(ctx, cfg, sourceStoreFactory)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := dbFactory(ctx, cfg, cfg.Active.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to load db driver.\n%v", err)
+	}
+	lockTimeout, err := config.ParseDuration(cfg.Settings.LockTimeout)
+	if err != nil {
+		database.Close()
+		return nil, nil, fmt.Errorf("Invalid lock timeout: %v", err)
+	}
+	return migrations.NewMigrator(database, stores, migrations.WithReporter(out.reporter), migrations.WithLockTimeout(lockTimeout)), func() { database.Close() }, nil
+}
+
+func newOfflineMigrator(ctx context.Context, out *output, cfg *config.Config, sourceStoreFactory source.StoreFactory, loadStores storeLoader) (*migrations.Migrator, error) {
+	stores, err := loadStores(ctx, cfg, sourceStoreFactory)
+	if err != nil {
+		return nil, err
+	}
+	return migrations.NewMigrator(nil, stores, migrations.WithReporter(out.reporter)), nil
 }
 
 func parseSteps(input string) (int, error) {
@@ -165,3 +477,18 @@ func parseSteps(input string) (int, error) {
 	}
 	return steps, nil
 }
+
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/migrations"
+	"github.com/easynow112/dbkit/report"
+	"github.com/easynow112/dbkit/source"
+	"github.com/easynow112/dbkit/source/sourcetest"
+)
+
+func discardOutput() *output {
+	return &output{
+		format:   outputText,
+		w:        io.Discard,
+		prompt:   io.Discard,
+		reporter: report.Discard,
+	}
+}
+
+func testConfig(overrides config.Overrides) (*config.Config, error) {
+	return &config.Config{
+		Active: config.ActiveConfig{
+			Source: config.Source{
+				Migrations: config.Migrations{Combined: "migrations"},
+				Seeds:      "seeds",
+			},
+			Database: "main",
+		},
+	}, nil
+}
+
+func sourceStores(stores map[string]source.Store) source.StoreFactory {
+	return func(ctx context.Context, cfg *config.Config, target string) (source.Store, error) {
+		store, ok := stores[target]
+		if !ok {
+			return nil, fmt.Errorf("unknown source %s", target)
+		}
+		return store, nil
+	}
+}
+
+func unavailableDB(opened *bool) db.DBFactory {
+	return func(ctx context.Context, cfg *config.Config, target string) (db.DB, error) {
+		*opened = true
+		return nil, fmt.Errorf("database %s is unavailable", target)
+	}
+}
+
+func TestHandleSeed(t *testing.T) {
+	t.Run("dry run does not open the database", func(t *testing.T) {
+		seeds := sourcetest.NewStore()
+		seeds.Create(t.Context(), "users", "INSERT INTO users (id) VALUES (1);")
+		var opened bool
+		err := run([]string{"dbkit", "seed", "--dry-run"}, discardOutput(), testConfig, sourceStores(map[string]source.Store{"seeds": seeds}), unavailableDB(&opened))
+		if err != nil {
+			t.Fatalf("failed to plan seeds: %v", err)
+		}
+		if opened {
+			t.Fatalf("expected dry run not to open the database")
+		}
+	})
+
+	t.Run("running seeds opens the database", func(t *testing.T) {
+		seeds := sourcetest.NewStore()
+		seeds.Create(t.Context(), "users", "INSERT INTO users (id) VALUES (1);")
+		var opened bool
+		err := run([]string{"dbkit", "seed"}, discardOutput(), testConfig, sourceStores(map[string]source.Store{"seeds": seeds}), unavailableDB(&opened))
+		if err == nil || !opened {
+			t.Fatalf("expected seeding to fail on the unavailable database, got %v", err)
+		}
+	})
+}
+
+func TestPrintRepairResults(t *testing.T) {
+	var buf bytes.Buffer
+	out := discardOutput()
+	out.w = &buf
+	out.printRepairResults([]migrations.RepairResult{
+		{Id: "a", Action: migrations.RepairApplied, Up: true},
+		{Id: "b", Action: migrations.RepairRemove, Up: true},
+		{Id: "c", Action: migrations.RepairRetry, Up: true},
+		{Id: "d", Action: migrations.RepairRetry, Up: false},
+		{Id: "e", Action: migrations.RepairSkip, Up: true},
+	}, nil)
+	want := "✅  Migration a marked as applied\n" +
+		"✅  Migration b removed from applied migrations\n" +
+		"✅  Migration c retried and applied\n" +
+		"✅  Rollback of migration d retried and completed\n" +
+		"⏭️  Migration e skipped\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
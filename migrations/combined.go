@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/source"
+)
+
+const (
+	upMarker   = "-- +dbkit Up"
+	downMarker = "-- +dbkit Down"
+)
+
+const combinedTemplate = upMarker + "\n\n\n" + downMarker + "\n\n"
+
+func splitCombined(contents string) (up string, down string, err error) {
+	sections := map[string]*strings.Builder{}
+	var current *strings.Builder
+	for i, line := range strings.SplitAfter(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.EqualFold(trimmed, upMarker) || strings.EqualFold(trimmed, downMarker) {
+			marker := upMarker
+			if strings.EqualFold(trimmed, downMarker) {
+				marker = downMarker
+			}
+			if _, ok := sections[marker]; ok {
+				return "", "", fmt.Errorf("line %d: duplicate \"%s\" marker", i+1, marker)
+			}
+			current = &strings.Builder{}
+			sections[marker] = current
+			continue
+		}
+		if current == nil {
+			if strings.HasPrefix(trimmed, directivePrefix) {
+				return "", "", fmt.Errorf("line %d: directive found before \"%s\" marker, move it into the section it applies to", i+1, upMarker)
+			}
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return "", "", fmt.Errorf("line %d: statement found before \"%s\" marker", i+1, upMarker)
+			}
+			continue
+		}
+		current.WriteString(line)
+	}
+	for _, marker := range []string{upMarker, downMarker} {
+		if _, ok := sections[marker]; !ok {
+			return "", "", fmt.Errorf("missing \"%s\" marker", marker)
+		}
+	}
+	return strings.TrimSpace(sections[upMarker].String()), strings.TrimSpace(sections[downMarker].String()), nil
+}
+
+func combinedSection(combined *source.Source, up bool) *source.Source {
+	return &source.Source{
+		Id: combined.Id,
+		Contents: func(ctx context.Context) (string, error) {
+			contents, err := combined.Contents(ctx)
+			if err != nil {
+				return "", err
+			}
+			upContents, downContents, err := splitCombined(contents)
+			if err != nil {
+				return "", &apperrors.Validation{Err: fmt.Errorf("invalid migration %s: %w", combined.Id, err)}
+			}
+			if up {
+				return upContents, nil
+			}
+			return downContents, nil
+		},
+	}
+}
+
+func newCombinedMigrationSource(combined *source.Source) *migrationSource {
+	return &migrationSource{
+		id:   combined.Id,
+		up:   combinedSection(combined, true),
+		down: combinedSection(combined, false),
+	}
+}
@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitCombined(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		wantUp   string
+		wantDown string
+		wantErr  string
+	}{
+		{
+			name:     "up and down sections",
+			contents: "-- +dbkit Up\nCREATE TABLE users (id INTEGER);\n\n-- +dbkit Down\nDROP TABLE users;\n",
+			wantUp:   "CREATE TABLE users (id INTEGER);",
+			wantDown: "DROP TABLE users;",
+		},
+		{
+			name:     "down section first",
+			contents: "-- +dbkit Down\nDROP TABLE users;\n-- +dbkit Up\nCREATE TABLE users (id INTEGER);\n",
+			wantUp:   "CREATE TABLE users (id INTEGER);",
+			wantDown: "DROP TABLE users;",
+		},
+		{
+			name:     "markers are case insensitive and may be indented",
+			contents: "  -- +DBKIT UP\nSELECT 1;\n\t-- +dbkit down  \nSELECT 2;",
+			wantUp:   "SELECT 1;",
+			wantDown: "SELECT 2;",
+		},
+		{
+			name:     "comments before the up marker are ignored",
+			contents: "-- creates the users table\n\n-- +dbkit Up\nSELECT 1;\n-- +dbkit Down\nSELECT 2;",
+			wantUp:   "SELECT 1;",
+			wantDown: "SELECT 2;",
+		},
+		{
+			name:     "directives stay in their section",
+			contents: "-- +dbkit Up\n-- dbkit:no-transaction\nCREATE INDEX CONCURRENTLY users_id ON users (id);\n-- +dbkit Down\n-- dbkit:timeout=5m\nDROP INDEX users_id;",
+			wantUp:   "-- dbkit:no-transaction\nCREATE INDEX CONCURRENTLY users_id ON users (id);",
+			wantDown: "-- dbkit:timeout=5m\nDROP INDEX users_id;",
+		},
+		{
+			name:     "empty sections",
+			contents: combinedTemplate,
+			wantUp:   "",
+			wantDown: "",
+		},
+		{
+			name:     "directive before the up marker",
+			contents: "-- about this migration\n-- dbkit:no-transaction\n-- +dbkit Up\nSELECT 1;\n-- +dbkit Down\nSELECT 2;",
+			wantErr:  "line 2: directive found before \"-- +dbkit Up\" marker",
+		},
+		{
+			name:     "statement before the up marker",
+			contents: "\nSELECT 0;\n-- +dbkit Up\nSELECT 1;\n-- +dbkit Down\nSELECT 2;",
+			wantErr:  "line 2: statement found before \"-- +dbkit Up\" marker",
+		},
+		{
+			name:     "duplicate marker",
+			contents: "-- +dbkit Up\nSELECT 1;\n-- +dbkit Down\nSELECT 2;\n-- +dbkit Up\nSELECT 3;",
+			wantErr:  "line 5: duplicate \"-- +dbkit Up\" marker",
+		},
+		{
+			name:     "missing down marker",
+			contents: "-- +dbkit Up\nSELECT 1;",
+			wantErr:  "missing \"-- +dbkit Down\" marker",
+		},
+		{
+			name:     "missing up marker",
+			contents: "-- +dbkit Down\nSELECT 2;",
+			wantErr:  "missing \"-- +dbkit Up\" marker",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			up, down, err := splitCombined(c.contents)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to split migration: %v", err)
+			}
+			if up != c.wantUp {
+				t.Fatalf("expected up %q, got %q", c.wantUp, up)
+			}
+			if down != c.wantDown {
+				t.Fatalf("expected down %q, got %q", c.wantDown, down)
+			}
+		})
+	}
+}
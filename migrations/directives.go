@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const directivePrefix = "-- dbkit:"
+
+type directives struct {
+	noTransaction bool
+	timeout       time.Duration
+}
+
+func parseDirectives(contents string) (directives, error) {
+	var parsed directives
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, directivePrefix)), "=")
+		switch {
+		case name == "no-transaction" && !hasValue:
+			parsed.noTransaction = true
+		case name == "timeout" && hasValue:
+			timeout, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil || timeout <= 0 {
+				return directives{}, fmt.Errorf("invalid timeout in directive %s, expected a positive duration such as 15m", line)
+			}
+			parsed.timeout = timeout
+		default:
+			return directives{}, fmt.Errorf("unknown directive: %s", line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return directives{}, err
+	}
+	return parsed, nil
+}
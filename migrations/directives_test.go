@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		want     directives
+		wantErr  string
+	}{
+		{
+			name:     "no directives",
+			contents: "-- creates users\nCREATE TABLE users (id INTEGER);",
+			want:     directives{},
+		},
+		{
+			name:     "no-transaction",
+			contents: "-- dbkit:no-transaction\nCREATE INDEX CONCURRENTLY users_id ON users (id);",
+			want:     directives{noTransaction: true},
+		},
+		{
+			name:     "timeout",
+			contents: "\n  -- dbkit:timeout=15m\nUPDATE users SET active = true;",
+			want:     directives{timeout: 15 * time.Minute},
+		},
+		{
+			name:     "directives mixed with comments",
+			contents: "-- backfill\n-- dbkit:timeout=1h\n-- dbkit:no-transaction\nUPDATE users SET active = true;",
+			want:     directives{noTransaction: true, timeout: time.Hour},
+		},
+		{
+			name:     "directives after the first statement are ignored",
+			contents: "UPDATE users SET active = true;\n-- dbkit:timeout=1h",
+			want:     directives{},
+		},
+		{
+			name:     "timeout must be a duration",
+			contents: "-- dbkit:timeout=soon",
+			wantErr:  "invalid timeout in directive -- dbkit:timeout=soon",
+		},
+		{
+			name:     "timeout must be positive",
+			contents: "-- dbkit:timeout=0s",
+			wantErr:  "expected a positive duration",
+		},
+		{
+			name:     "timeout requires a value",
+			contents: "-- dbkit:timeout",
+			wantErr:  "unknown directive: -- dbkit:timeout",
+		},
+		{
+			name:     "no-transaction takes no value",
+			contents: "-- dbkit:no-transaction=true",
+			wantErr:  "unknown directive: -- dbkit:no-transaction=true",
+		},
+		{
+			name:     "unknown directive",
+			contents: "-- dbkit:retries=3",
+			wantErr:  "unknown directive: -- dbkit:retries=3",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			parsed, err := parseDirectives(c.contents)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse directives: %v", err)
+			}
+			if parsed != c.want {
+				t.Fatalf("expected %+v, got %+v", c.want, parsed)
+			}
+		})
+	}
+}
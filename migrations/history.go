@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/version"
+)
+
+type HistoryEntry struct {
+	Id         string        `json:"id"`
+	Action     string        `json:"action"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Duration   time.Duration `json:"durationNs"`
+	AppliedBy  string        `json:"appliedBy,omitempty"`
+	Host       string        `json:"host,omitempty"`
+	Version    string        `json:"version,omitempty"`
+	Note       string        `json:"note,omitempty"`
+}
+
+func (m *Migrator) History(ctx context.Context) ([]HistoryEntry, error) {
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	store := conn.AppliedMigrationStore()
+	exists, err := store.SchemaExists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check applied migration schema: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	entries, err := store.History(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list migration history: %w", err)
+	}
+	history := make([]HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		history = append(history, HistoryEntry{
+			Id:         entry.MigrationId,
+			Action:     entry.Action,
+			StartedAt:  entry.StartedAt,
+			FinishedAt: entry.FinishedAt,
+			Duration:   entry.Duration,
+			AppliedBy:  entry.AppliedBy,
+			Host:       entry.Host,
+			Version:    entry.Version,
+			Note:       entry.Note,
+		})
+	}
+	return history, nil
+}
+
+func (m *Migrator) runInfo(note string) db.RunInfo {
+	owner := db.CurrentLockOwner()
+	return db.RunInfo{
+		AppliedBy: owner.User,
+		Host:      owner.Host,
+		Version:   version.String(),
+		Note:      note,
+	}
+}
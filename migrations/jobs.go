@@ -2,52 +2,54 @@ package migrations
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	"github.com/easynow112/dbkit/report"
 	"github.com/easynow112/dbkit/source"
 )
 
 type createMigrationJob struct {
-	src     source.Store
-	label   string
-	id      string
-	content string
+	src      source.Store
+	label    string
+	id       string
+	content  string
+	reporter report.Reporter
 }
 
 func (job *createMigrationJob) Run(ctx context.Context) error {
-	return createMigration(ctx, job.src, job.label, job.id, job.content)
+	return createMigration(ctx, job.src, job.label, job.id, job.content, job.reporter)
 }
 
 func (job *createMigrationJob) Rollback(ctx context.Context) error {
-	return removeMigration(ctx, job.src, job.label, job.id)
+	return removeMigration(ctx, job.src, job.label, job.id, job.reporter)
 }
 
-func newCreateMigrationJob(src source.Store, label, id, content string) *createMigrationJob {
+func newCreateMigrationJob(src source.Store, label, id, content string, reporter report.Reporter) *createMigrationJob {
 	return &createMigrationJob{
-		src:     src,
-		label:   label,
-		id:      id,
-		content: content,
+		src:      src,
+		label:    label,
+		id:       id,
+		content:  content,
+		reporter: reporter,
 	}
 }
 
-func createMigration(ctx context.Context, src source.Store, label, id, content string) error {
+func createMigration(ctx context.Context, src source.Store, label, id, content string, reporter report.Reporter) error {
 	err := src.Create(ctx, id, content)
 	if err != nil {
-		fmt.Printf("❌ error when creating %s migration:\n%v\n", label, err)
+		reporter.Report(report.Event{Kind: report.MigrationCreateFailed, Time: time.Now(), Id: id, Direction: label, Err: err})
 		return err
 	}
-	fmt.Printf("✅ %s migration created successfully: %s\n", label, id)
+	reporter.Report(report.Event{Kind: report.MigrationCreated, Time: time.Now(), Id: id, Direction: label})
 	return nil
 }
 
-func removeMigration(ctx context.Context, src source.Store, label, id string) error {
-	fmt.Printf("attempting to remove %s migration...\n", label)
+func removeMigration(ctx context.Context, src source.Store, label, id string, reporter report.Reporter) error {
 	err := src.Remove(ctx, id)
 	if err != nil {
-		fmt.Printf("❌ error when removing %s migration:\n%v\n", label, err)
+		reporter.Report(report.Event{Kind: report.MigrationRemoveFailed, Time: time.Now(), Id: id, Direction: label, Err: err})
 		return err
 	}
-	fmt.Printf("%s migration removed successfully\n", label)
+	reporter.Report(report.Event{Kind: report.MigrationRemoved, Time: time.Now(), Id: id, Direction: label})
 	return nil
 }
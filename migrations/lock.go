@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+type LockStatus struct {
+	Held       bool       `json:"held"`
+	User       string     `json:"user,omitempty"`
+	Host       string     `json:"host,omitempty"`
+	Pid        int        `json:"pid,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	Expires    *time.Time `json:"expires,omitempty"`
+	BackendPid int        `json:"backendPid,omitempty"`
+}
+
+func (m *Migrator) LockStatus(ctx context.Context) (LockStatus, error) {
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return LockStatus{}, err
+	}
+	defer conn.Close()
+
+	status, err := conn.LockStatus(ctx)
+	if err != nil {
+		return LockStatus{}, fmt.Errorf("Failed to check lock status: %w", err)
+	}
+	return newLockStatus(status), nil
+}
+
+func (m *Migrator) ForceReleaseLock(ctx context.Context, terminate bool) (LockStatus, error) {
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return LockStatus{}, err
+	}
+	defer conn.Close()
+
+	status, err := conn.ForceReleaseLock(ctx, terminate)
+	if err != nil {
+		return newLockStatus(status), fmt.Errorf("Failed to release lock: %w", err)
+	}
+	return newLockStatus(status), nil
+}
+
+func newLockStatus(status db.LockStatus) LockStatus {
+	return LockStatus{
+		Held:       status.Held,
+		User:       status.Owner.User,
+		Host:       status.Owner.Host,
+		Pid:        status.Owner.Pid,
+		Since:      status.Since,
+		Expires:    status.Expires,
+		BackendPid: status.BackendPid,
+	}
+}
@@ -0,0 +1,285 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/plan"
+	"github.com/easynow112/dbkit/report"
+	"github.com/easynow112/dbkit/seeds"
+	"github.com/easynow112/dbkit/source"
+)
+
+type Migrator struct {
+	db          db.DB
+	sourceStore *migrationSourceStore
+	seedStore   source.Store
+	reporter    report.Reporter
+	lockTimeout time.Duration
+}
+
+type Option func(m *Migrator)
+
+func WithMigrationStores(upStore source.Store, downStore source.Store) Option {
+	return func(m *Migrator) {
+		m.sourceStore = &migrationSourceStore{
+			upStore:   upStore,
+			downStore: downStore,
+		}
+	}
+}
+
+func WithCombinedMigrationStore(store source.Store) Option {
+	return func(m *Migrator) {
+		m.sourceStore = &migrationSourceStore{
+			combinedStore: store,
+		}
+	}
+}
+
+func WithSeedStore(store source.Store) Option {
+	return func(m *Migrator) {
+		m.seedStore = store
+	}
+}
+
+func WithReporter(reporter report.Reporter) Option {
+	return func(m *Migrator) {
+		m.reporter = reporter
+	}
+}
+
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTimeout = timeout
+	}
+}
+
+func NewMigrator(database db.DB, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:       database,
+		reporter: report.Discard,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+type RunOptions struct {
+	Steps           int
+	Target          string
+	DryRun          bool
+	PlanFile        string
+	AllowOutOfOrder bool
+	Note            string
+}
+
+type SeedOptions struct {
+	DryRun   bool
+	PlanFile string
+}
+
+type Result struct {
+	Direction string     `json:"direction,omitempty"`
+	DryRun    bool       `json:"dryRun"`
+	Plan      *plan.Plan `json:"plan,omitempty"`
+	Applied   []string   `json:"applied"`
+}
+
+func (m *Migrator) Up(ctx context.Context, opts RunOptions) (*Result, error) {
+	return m.run(ctx, opts, selectRange(true, opts))
+}
+
+func (m *Migrator) Down(ctx context.Context, opts RunOptions) (*Result, error) {
+	return m.run(ctx, opts, selectRange(false, opts))
+}
+
+func (m *Migrator) Goto(ctx context.Context, target string, opts RunOptions) (*Result, error) {
+	if opts.Steps != 0 || opts.Target != "" {
+		return nil, &apperrors.Validation{Err: fmt.Errorf("steps and target options cannot be used with goto")}
+	}
+	return m.run(ctx, opts, selectTarget(target, opts))
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	sourceStore, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	appliedMigrations, err := listAppliedMigrations(ctx, conn.AppliedMigrationStore())
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := sourceStore.list(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list migration sources: %w", err)
+	}
+
+	return migrationStatuses(ctx, sources, appliedMigrations)
+}
+
+func (m *Migrator) Seed(ctx context.Context, opts SeedOptions) (*Result, error) {
+	if m.seedStore == nil {
+		return nil, fmt.Errorf("no seed source store configured")
+	}
+
+	sources, err := m.seedStore.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list seeds from store.\n%w", err)
+	}
+
+	seedPlan, err := seeds.NewPlan(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		DryRun:  opts.DryRun,
+		Plan:    seedPlan,
+		Applied: make([]string, 0, len(sources)),
+	}
+	if err := writePlan(seedPlan, opts.PlanFile); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	lock, err := m.acquireLock(ctx, conn)
+	if err != nil {
+		return result, err
+	}
+	defer lock.Release(context.WithoutCancel(ctx))
+	ctx, stopWatching := m.watchLock(ctx, lock)
+	defer stopWatching()
+
+	for _, seed := range sources {
+		startedAt := time.Now()
+		if err := seeds.Exec(ctx, seed, conn); err != nil {
+			err = abortCause(ctx, &apperrors.SQLFailure{Err: fmt.Errorf("Failed to execute seed %s: %w", seed.Id, err)})
+			m.report(report.Event{Kind: report.SeedFailed, Id: seed.Id, Duration: time.Since(startedAt), Err: err})
+			return result, err
+		}
+		m.report(report.Event{Kind: report.SeedApplied, Id: seed.Id, Duration: time.Since(startedAt)})
+		result.Applied = append(result.Applied, seed.Id)
+	}
+	return result, nil
+}
+
+func writePlan(p *plan.Plan, path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := p.WriteFile(path); err != nil {
+		return fmt.Errorf("Failed to write plan: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) NewMigration(ctx context.Context, name string) (string, error) {
+	sourceStore, err := m.migrations()
+	if err != nil {
+		return "", err
+	}
+	if err := sourceStore.validate(ctx); err != nil {
+		return "", err
+	}
+	id := newId(name)
+	if err := sourceStore.Create(ctx, id, "", m.reporter); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (m *Migrator) NewSeed(ctx context.Context, name string) (string, error) {
+	if m.seedStore == nil {
+		return "", fmt.Errorf("no seed source store configured")
+	}
+	id := newId(name)
+	if err := m.seedStore.Create(ctx, id, ""); err != nil {
+		return "", err
+	}
+	m.report(report.Event{Kind: report.SeedCreated, Id: id})
+	return id, nil
+}
+
+func (m *Migrator) migrations() (*migrationSourceStore, error) {
+	if m.sourceStore == nil {
+		return nil, fmt.Errorf("no migration source stores configured")
+	}
+	return m.sourceStore, nil
+}
+
+func (m *Migrator) acquireConnection(ctx context.Context) (db.Connection, error) {
+	if m.db == nil {
+		return nil, fmt.Errorf("no database configured")
+	}
+	conn, err := m.db.AcquireConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to aquire db connection: %w", err)
+	}
+	return conn, nil
+}
+
+func (m *Migrator) acquireLock(ctx context.Context, conn db.Connection) (db.Lock, error) {
+	lock, err := conn.TryAcquireLock(ctx)
+	if errors.Is(err, db.ErrLockHeld) && m.lockTimeout > 0 {
+		m.report(report.Event{Kind: report.LockWaiting, Duration: m.lockTimeout})
+		lock, err = conn.AcquireLock(ctx, m.lockTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to acquire lock: %w", err)
+	}
+	m.report(report.Event{Kind: report.LockAcquired})
+	return lock, nil
+}
+
+func (m *Migrator) watchLock(ctx context.Context, lock db.Lock) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		select {
+		case <-lock.Lost():
+			m.report(report.Event{Kind: report.LockLost, Err: db.ErrLockLost})
+			cancel(db.ErrLockLost)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
+
+func abortCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, db.ErrLockLost) {
+		return fmt.Errorf("Aborted because the %w: %w", cause, err)
+	}
+	return err
+}
+
+func (m *Migrator) report(event report.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	m.reporter.Report(event)
+}
+
+func newId(name string) string {
+	return fmt.Sprintf("%s_%s", time.Now().Format("2006-01-02_15-04-05"), name)
+}
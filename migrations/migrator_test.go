@@ -0,0 +1,96 @@
+package migrations
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/db/test"
+	"github.com/easynow112/dbkit/plan"
+	"github.com/easynow112/dbkit/report"
+	"github.com/easynow112/dbkit/source/sourcetest"
+)
+
+type reporterFunc func(event report.Event)
+
+func (f reporterFunc) Report(event report.Event) {
+	f(event)
+}
+
+func newTestDB(t *testing.T, rows []db.AppliedMigration) (db.DB, *test.AppliedMigrationStore) {
+	t.Helper()
+	rowsById := make(map[string]*db.AppliedMigration, len(rows))
+	for _, row := range rows {
+		rowsById[row.Id] = &row
+	}
+	store := test.NewStore(rowsById)
+	database, err := test.NewFactory(store)(t.Context(), &config.DriverConfig{Driver: "test"}, &config.GlobalConfig{})
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database, store
+}
+
+func readPlan(t *testing.T, path string) *plan.Plan {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read plan file: %v", err)
+	}
+	var written plan.Plan
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("failed to decode plan file: %v", err)
+	}
+	return &written
+}
+
+func TestMigratorPlanFile(t *testing.T) {
+	t.Run("plan file is written before migrations run", func(t *testing.T) {
+		sourceStore := newSourceStore(t, "a", "b")
+		database, _ := newTestDB(t, nil)
+		planFile := filepath.Join(t.TempDir(), "plan.json")
+		var started int
+		reporter := reporterFunc(func(event report.Event) {
+			if event.Kind != report.MigrationStarted {
+				return
+			}
+			started++
+			if _, err := os.Stat(planFile); err != nil {
+				t.Errorf("expected plan file to exist before %s runs: %v", event.Id, err)
+			}
+		})
+		migrator := NewMigrator(database, WithCombinedMigrationStore(sourceStore.combinedStore), WithReporter(reporter))
+		if _, err := migrator.Up(t.Context(), RunOptions{PlanFile: planFile}); err != nil {
+			t.Fatalf("failed to migrate up: %v", err)
+		}
+		if started != 2 {
+			t.Fatalf("expected 2 migrations to run, got %d", started)
+		}
+		if written := readPlan(t, planFile); len(written.Steps) != 2 {
+			t.Fatalf("expected 2 planned steps, got %+v", written.Steps)
+		}
+	})
+
+	t.Run("seed dry run writes a plan without a database", func(t *testing.T) {
+		seedStore := sourcetest.NewStore()
+		if err := seedStore.Create(t.Context(), "users", "INSERT INTO users (id) VALUES (1);"); err != nil {
+			t.Fatalf("failed to create seed: %v", err)
+		}
+		planFile := filepath.Join(t.TempDir(), "seeds.json")
+		migrator := NewMigrator(nil, WithSeedStore(seedStore))
+		result, err := migrator.Seed(t.Context(), SeedOptions{DryRun: true, PlanFile: planFile})
+		if err != nil {
+			t.Fatalf("failed to plan seeds: %v", err)
+		}
+		if len(result.Plan.Steps) != 1 || len(result.Applied) != 0 {
+			t.Fatalf("expected 1 planned and no applied seeds, got %+v", result)
+		}
+		if written := readPlan(t, planFile); len(written.Steps) != 1 {
+			t.Fatalf("expected 1 planned step, got %+v", written.Steps)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/db"
+)
+
+type RepairAction string
+
+const (
+	RepairApplied RepairAction = "applied"
+	RepairRemove  RepairAction = "remove"
+	RepairRetry   RepairAction = "retry"
+	RepairSkip    RepairAction = "skip"
+)
+
+func ParseRepairAction(input string) (RepairAction, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "a", string(RepairApplied):
+		return RepairApplied, nil
+	case "r", string(RepairRemove):
+		return RepairRemove, nil
+	case "t", string(RepairRetry):
+		return RepairRetry, nil
+	case "s", string(RepairSkip):
+		return RepairSkip, nil
+	}
+	return "", fmt.Errorf("unknown repair action '%s', expected one of: %s, %s, %s, %s", input, RepairApplied, RepairRemove, RepairRetry, RepairSkip)
+}
+
+type RepairDecider func(status MigrationStatus) (RepairAction, error)
+
+type RepairResult struct {
+	Id     string       `json:"id"`
+	Action RepairAction `json:"action"`
+	Up     bool         `json:"up"`
+}
+
+func (m *Migrator) Repair(ctx context.Context, decide RepairDecider) ([]RepairResult, error) {
+	sourceStore, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.acquireConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	lock, err := m.acquireLock(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release(context.WithoutCancel(ctx))
+	ctx, stopWatching := m.watchLock(ctx, lock)
+	defer stopWatching()
+
+	appliedStore := conn.AppliedMigrationStore()
+
+	err = appliedStore.EnsureSchema(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to ensure applied migration schema exists: %w", err)
+	}
+
+	dirtyMigrations, err := appliedStore.ListDirty(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list dirty migrations: %w", err)
+	}
+
+	info := m.runInfo("")
+	results := make([]RepairResult, 0, len(dirtyMigrations))
+	for _, dirtyMigration := range dirtyMigrations {
+		action, err := decide(appliedStatus(dirtyMigration))
+		if err != nil {
+			return results, err
+		}
+		result, err := m.repairMigration(ctx, dirtyMigration, action, sourceStore, conn, info)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (m *Migrator) repairMigration(ctx context.Context, dirtyMigration db.AppliedMigration, action RepairAction, sourceStore *migrationSourceStore, conn db.Connection, info db.RunInfo) (RepairResult, error) {
+	store := conn.AppliedMigrationStore()
+	result := RepairResult{
+		Id:     dirtyMigration.Id,
+		Action: action,
+		Up:     dirtyMigration.RollbackStartedAt == nil,
+	}
+	id := dirtyMigration.Id
+	switch action {
+	case RepairApplied:
+		if err := store.MarkApplied(ctx, id); err != nil {
+			return result, fmt.Errorf("Failed to mark migration %s as applied: %w", id, err)
+		}
+		if err := recordRepair(ctx, store, dirtyMigration, action, info); err != nil {
+			return result, err
+		}
+	case RepairRemove:
+		if err := store.Remove(ctx, id); err != nil {
+			return result, fmt.Errorf("Failed to remove migration %s: %w", id, err)
+		}
+		if err := recordRepair(ctx, store, dirtyMigration, action, info); err != nil {
+			return result, err
+		}
+	case RepairRetry:
+		retrySource, err := sourceStore.find(ctx, id)
+		if err != nil {
+			return result, fmt.Errorf("Failed to list migration sources: %w", err)
+		}
+		if retrySource == nil {
+			return result, &apperrors.Validation{Err: fmt.Errorf("Cannot retry migration %s, it is missing from source", id)}
+		}
+		if result.Up {
+			if err := store.Remove(ctx, id); err != nil {
+				return result, fmt.Errorf("Failed to reset migration %s before retrying: %w", id, err)
+			}
+		}
+		if err := m.runMigration(ctx, retrySource, conn, result.Up, info); err != nil {
+			return result, err
+		}
+	case RepairSkip:
+	default:
+		return result, fmt.Errorf("unknown repair action '%s'", action)
+	}
+	return result, nil
+}
+
+func recordRepair(ctx context.Context, store db.AppliedMigrationStore, dirtyMigration db.AppliedMigration, action RepairAction, info db.RunInfo) error {
+	now := time.Now()
+	err := store.RecordHistory(ctx, db.HistoryEntry{
+		MigrationId: dirtyMigration.Id,
+		Action:      "repair " + string(action),
+		Checksum:    dirtyMigration.Checksum,
+		StartedAt:   now,
+		FinishedAt:  now,
+		RunInfo:     info,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record migration %s history: %w", dirtyMigration.Id, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+func TestMigratorRepair(t *testing.T) {
+	unfinished := func(row *db.AppliedMigration) {
+		row.FinishedAt = nil
+	}
+	rollingBack := func(row *db.AppliedMigration) {
+		rollbackStartedAt := time.Now()
+		row.RollbackStartedAt = &rollbackStartedAt
+	}
+
+	cases := []struct {
+		name        string
+		dirty       func(row *db.AppliedMigration)
+		action      RepairAction
+		wantUp      bool
+		wantRow     bool
+		wantHistory []string
+	}{
+		{"applied marks an unfinished migration as finished", unfinished, RepairApplied, true, true, []string{"repair applied"}},
+		{"applied clears an interrupted rollback", rollingBack, RepairApplied, false, true, []string{"repair applied"}},
+		{"remove deletes an unfinished migration", unfinished, RepairRemove, true, false, []string{"repair remove"}},
+		{"remove deletes an interrupted rollback", rollingBack, RepairRemove, false, false, []string{"repair remove"}},
+		{"retry reruns an unfinished migration", unfinished, RepairRetry, true, true, []string{"up"}},
+		{"retry reruns an interrupted rollback", rollingBack, RepairRetry, false, false, []string{"down"}},
+		{"skip leaves the migration dirty", unfinished, RepairSkip, true, true, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sourceStore := newSourceStore(t, "a", "b")
+			rows := appliedRows(t, sourceStore, "a", "b")
+			c.dirty(&rows[1])
+			database, store := newTestDB(t, rows)
+
+			var decided []MigrationStatus
+			migrator := NewMigrator(database, WithCombinedMigrationStore(sourceStore.combinedStore))
+			results, err := migrator.Repair(t.Context(), func(status MigrationStatus) (RepairAction, error) {
+				decided = append(decided, status)
+				return c.action, nil
+			})
+			if err != nil {
+				t.Fatalf("failed to repair: %v", err)
+			}
+			if len(decided) != 1 || decided[0].Id != "b" {
+				t.Fatalf("expected only b to be offered for repair, got %+v", decided)
+			}
+			if len(results) != 1 || results[0] != (RepairResult{Id: "b", Action: c.action, Up: c.wantUp}) {
+				t.Fatalf("expected repair result for b, got %+v", results)
+			}
+
+			applied, err := store.List(t.Context())
+			if err != nil {
+				t.Fatalf("failed to list applied migrations: %v", err)
+			}
+			index := slices.IndexFunc(applied, func(row db.AppliedMigration) bool { return row.Id == "b" })
+			if (index != -1) != c.wantRow {
+				t.Fatalf("expected b to be applied: %v, got %+v", c.wantRow, applied)
+			}
+			if index != -1 && c.action != RepairSkip {
+				row := applied[index]
+				if row.FinishedAt == nil || row.RollbackStartedAt != nil {
+					t.Fatalf("expected b to be cleanly applied, got %+v", row)
+				}
+				if row.Checksum != rows[1].Checksum {
+					t.Fatalf("expected b checksum %s, got %s", rows[1].Checksum, row.Checksum)
+				}
+			}
+			if !slices.ContainsFunc(applied, func(row db.AppliedMigration) bool { return row.Id == "a" && row.FinishedAt != nil }) {
+				t.Fatalf("expected a to be left applied, got %+v", applied)
+			}
+
+			history, err := store.History(t.Context())
+			if err != nil {
+				t.Fatalf("failed to list history: %v", err)
+			}
+			var actions []string
+			for _, entry := range history {
+				if entry.MigrationId != "b" {
+					t.Fatalf("expected history only for b, got %+v", entry)
+				}
+				actions = append(actions, entry.Action)
+			}
+			if !slices.Equal(actions, c.wantHistory) {
+				t.Fatalf("expected history %v, got %v", c.wantHistory, actions)
+			}
+		})
+	}
+
+	t.Run("retry fails when the migration is missing from source", func(t *testing.T) {
+		sourceStore := newSourceStore(t, "a")
+		rows := appliedRows(t, sourceStore, "a", "b")
+		unfinished(&rows[1])
+		database, store := newTestDB(t, rows)
+
+		migrator := NewMigrator(database, WithCombinedMigrationStore(sourceStore.combinedStore))
+		_, err := migrator.Repair(t.Context(), func(status MigrationStatus) (RepairAction, error) {
+			return RepairRetry, nil
+		})
+		assertValidationError(t, err, "missing from source")
+
+		dirty, err := store.ListDirty(t.Context())
+		if err != nil {
+			t.Fatalf("failed to list dirty migrations: %v", err)
+		}
+		if len(dirty) != 1 || dirty[0].Id != "b" {
+			t.Fatalf("expected b to remain dirty, got %+v", dirty)
+		}
+	})
+}
@@ -2,137 +2,344 @@ package migrations
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
-	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/apperrors"
 	"github.com/easynow112/dbkit/db"
-	"github.com/easynow112/dbkit/source"
+	"github.com/easynow112/dbkit/plan"
+	"github.com/easynow112/dbkit/report"
 )
 
-func Run(ctx context.Context, up bool, steps *int, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
+type executor interface {
+	Exec(ctx context.Context, query string, args ...any) error
+}
 
-	upStore, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Migrations.Up)
-	if err != nil {
-		return err
-	}
+type planner func(ctx context.Context, sourceStore *migrationSourceStore, appliedMigrations []db.AppliedMigration) (up bool, pending []*migrationSource, err error)
 
-	downStore, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Migrations.Down)
-	if err != nil {
-		return err
+func selectRange(up bool, opts RunOptions) planner {
+	return func(ctx context.Context, sourceStore *migrationSourceStore, appliedMigrations []db.AppliedMigration) (bool, []*migrationSource, error) {
+		pending, err := sourceStore.GetPending(ctx, appliedMigrations, up, opts.AllowOutOfOrder)
+		if err != nil {
+			return up, nil, err
+		}
+		if opts.Target != "" {
+			pending, err = pendingUntil(ctx, sourceStore, pending, opts.Target, up)
+			if err != nil {
+				return up, nil, err
+			}
+		}
+		if opts.Steps > 0 && opts.Steps < len(pending) {
+			pending = pending[:opts.Steps]
+		}
+		return up, pending, nil
 	}
+}
 
-	sourceStore, err := newMigrationSourceStore(ctx, upStore, downStore)
-	if err != nil {
-		return err
+func selectTarget(target string, opts RunOptions) planner {
+	return func(ctx context.Context, sourceStore *migrationSourceStore, appliedMigrations []db.AppliedMigration) (bool, []*migrationSource, error) {
+		up := !slices.ContainsFunc(appliedMigrations, func(applied db.AppliedMigration) bool {
+			return applied.Id == target
+		})
+		pending, err := sourceStore.GetPending(ctx, appliedMigrations, up, opts.AllowOutOfOrder)
+		if err != nil {
+			return up, nil, err
+		}
+		pending, err = pendingUntil(ctx, sourceStore, pending, target, up)
+		if err != nil {
+			return up, nil, err
+		}
+		return up, pending, nil
 	}
+}
 
-	db, err := dbFactory(ctx, cfg, cfg.Active.Database)
+func pendingUntil(ctx context.Context, sourceStore *migrationSourceStore, pending []*migrationSource, target string, up bool) ([]*migrationSource, error) {
+	targetSource, err := sourceStore.find(ctx, target)
 	if err != nil {
-		return fmt.Errorf("Failed to load db driver.\n%v", err)
+		return nil, err
+	}
+	if targetSource == nil {
+		return nil, &apperrors.Validation{Err: fmt.Errorf("migration %s does not exist in source", target)}
 	}
-	defer db.Close()
+	index := slices.IndexFunc(pending, func(source *migrationSource) bool {
+		return source.id == target
+	})
+	if up {
+		if index == -1 {
+			return nil, nil
+		}
+		return pending[:index+1], nil
+	}
+	if index == -1 {
+		return nil, &apperrors.Validation{Err: fmt.Errorf("migration %s has not been applied", target)}
+	}
+	return pending[:index], nil
+}
 
-	conn, err := db.AcquireConnection(ctx)
+func (m *Migrator) run(ctx context.Context, opts RunOptions, selectPending planner) (*Result, error) {
+	sourceStore, err := m.migrations()
 	if err != nil {
-		return fmt.Errorf("Failed to aquire db connection: %v", err)
+		return nil, err
 	}
-	defer conn.Close()
 
-	lock, err := conn.TryAcquireLock(ctx)
+	conn, err := m.acquireConnection(ctx)
 	if err != nil {
-		return fmt.Errorf("Failed to acquire lock: %v", err)
+		return nil, err
 	}
-	defer lock.Release(ctx)
+	defer conn.Close()
 
 	appliedStore := conn.AppliedMigrationStore()
 
-	err = appliedStore.EnsureSchema(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to ensure applied migration schema exists: %v", err)
+	var appliedMigrations []db.AppliedMigration
+	if opts.DryRun {
+		appliedMigrations, err = listAppliedMigrations(ctx, appliedStore)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		lock, err := m.acquireLock(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Release(context.WithoutCancel(ctx))
+		var stopWatching context.CancelFunc
+		ctx, stopWatching = m.watchLock(ctx, lock)
+		defer stopWatching()
+
+		err = appliedStore.EnsureSchema(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to ensure applied migration schema exists: %w", err)
+		}
+
+		appliedMigrations, err = appliedStore.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list applied migrations: %w", err)
+		}
 	}
 
-	appliedMigrations, err := appliedStore.List(ctx)
+	err = ensureCleanState(appliedMigrations)
 	if err != nil {
-		return fmt.Errorf("Failed to list applied migrations: %v", err)
+		return nil, &apperrors.Validation{Err: fmt.Errorf("Corrupted db state: %w", err)}
 	}
 
-	err = ensureCleanState(appliedMigrations)
+	up, pending, err := selectPending(ctx, sourceStore, appliedMigrations)
 	if err != nil {
-		return fmt.Errorf("Corrupted db state: %v", err)
+		return nil, fmt.Errorf("Failed to get pending migrations: %w", err)
 	}
 
-	pending, err := sourceStore.GetPending(ctx, appliedMigrations, up)
+	migrationPlan, err := newMigrationPlan(ctx, pending, up)
 	if err != nil {
-		return fmt.Errorf("Failed to get pending migrations: %v", err)
+		return nil, err
 	}
 
-	if len(pending) == 0 {
-		fmt.Printf("✅  No pending %s migrations\n", direction(up))
-		return nil
+	result := &Result{
+		Direction: direction(up),
+		DryRun:    opts.DryRun,
+		Plan:      migrationPlan,
+		Applied:   make([]string, 0, len(pending)),
+	}
+	if err := writePlan(migrationPlan, opts.PlanFile); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return result, nil
 	}
-	for i, pendingSource := range pending {
-		if steps != nil && *steps <= i {
-			return nil
-		}
 
-		upContents, downContents, checksum, err := pendingSource.contents(ctx)
-		if err != nil {
-			return err
-		}
-		var contents string
-		if up {
-			contents = upContents
-		} else {
-			contents = downContents
+	m.warnDDLCommits(conn, migrationPlan)
+	info := m.runInfo(opts.Note)
+	for _, pendingSource := range pending {
+		if err := m.runMigration(ctx, pendingSource, conn, up, info); err != nil {
+			return result, abortCause(ctx, err)
 		}
+		result.Applied = append(result.Applied, pendingSource.id)
+	}
+	return result, nil
+}
 
-		err = startMigration(ctx, pendingSource.id, checksum, appliedStore, up)
-		if err != nil {
-			return err
-		}
-		err = execMigration(ctx, pendingSource.id, contents, conn, up)
+func (m *Migrator) warnDDLCommits(conn db.Connection, migrationPlan *plan.Plan) {
+	committer, ok := conn.(db.DDLCommitter)
+	if !ok || !committer.CommitsDDL() {
+		return
+	}
+	if !slices.ContainsFunc(migrationPlan.Steps, func(step plan.Step) bool { return step.Transactional }) {
+		return
+	}
+	m.report(report.Event{
+		Kind:    report.MigrationWarning,
+		Time:    time.Now(),
+		Message: "This database commits DDL statements implicitly, a failed migration may keep the statements before the failure and need manual cleanup",
+	})
+}
+
+func newMigrationPlan(ctx context.Context, pending []*migrationSource, up bool) (*plan.Plan, error) {
+	migrationPlan := plan.New("migration")
+	for _, pendingSource := range pending {
+		contents, _, directives, err := pendingSource.script(ctx, up)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = finishMigration(ctx, pendingSource.id, appliedStore, up)
+		migrationPlan.Add(plan.Step{
+			Id:            pendingSource.id,
+			Direction:     direction(up),
+			Transactional: !directives.noTransaction,
+			Timeout:       directives.timeout,
+			SQL:           contents,
+		})
+	}
+	return migrationPlan, nil
+}
+
+type migrationRun struct {
+	id        string
+	checksum  string
+	contents  string
+	timeout   time.Duration
+	up        bool
+	info      db.RunInfo
+	startedAt time.Time
+}
+
+func (m *Migrator) runMigration(ctx context.Context, source *migrationSource, conn db.Connection, up bool, info db.RunInfo) error {
+	contents, checksum, directives, err := source.script(ctx, up)
+	if err != nil {
+		return err
+	}
+
+	run := &migrationRun{
+		id:        source.id,
+		checksum:  checksum,
+		contents:  contents,
+		timeout:   directives.timeout,
+		up:        up,
+		info:      info,
+		startedAt: time.Now(),
+	}
+	m.report(report.Event{Kind: report.MigrationStarted, Time: run.startedAt, Id: run.id, Direction: direction(up)})
+	if run.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeoutOverride(ctx, run.timeout)
+		defer cancel()
+	}
+	if directives.noTransaction {
+		err = applyMigrationWithoutTransaction(ctx, run, conn)
+	} else {
+		err = applyMigration(ctx, run, conn)
+	}
+	if err != nil {
+		m.report(report.Event{Kind: report.MigrationFailed, Id: run.id, Direction: direction(up), Duration: time.Since(run.startedAt), Err: err})
+		return err
+	}
+	m.report(report.Event{Kind: report.MigrationFinished, Id: run.id, Direction: direction(up), Duration: time.Since(run.startedAt)})
+	return nil
+}
+
+func applyMigration(ctx context.Context, run *migrationRun, conn db.Connection) (err error) {
+	trx, err := conn.BeginTrx(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction for migration %s: %w", run.id, err)
+	}
+	defer func() {
 		if err != nil {
-			return err
+			trx.Rollback(context.WithoutCancel(ctx))
 		}
+	}()
+
+	store := trx.AppliedMigrationStore()
+
+	if err = startMigration(ctx, run, store); err != nil {
+		return err
+	}
+	if err = execMigration(ctx, run, trx); err != nil {
+		return err
+	}
+	if err = finishMigration(ctx, run, store); err != nil {
+		return err
+	}
+	if err = trx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit migration %s: %w", run.id, err)
 	}
 	return nil
 }
 
-func startMigration(ctx context.Context, id string, checksum string, store db.AppliedMigrationStore, up bool) error {
-	if up {
-		if err := store.RecordStarted(ctx, id, checksum); err != nil {
-			return fmt.Errorf("Failed to record migration %s start: %v", id, err)
+func applyMigrationWithoutTransaction(ctx context.Context, run *migrationRun, conn db.Connection) error {
+	store := conn.AppliedMigrationStore()
+
+	if err := startMigration(ctx, run, store); err != nil {
+		return err
+	}
+	if err := execMigration(ctx, run, conn); err != nil {
+		return &apperrors.SQLFailure{Err: fmt.Errorf("%w\nMigration %s is marked \"%sno-transaction\" and ran outside a transaction, it may have been partially applied and require manual cleanup", err, run.id, directivePrefix)}
+	}
+	if err := finishMigration(ctx, run, store); err != nil {
+		return err
+	}
+	return nil
+}
+
+func startMigration(ctx context.Context, run *migrationRun, store db.AppliedMigrationStore) error {
+	if run.up {
+		if err := store.RecordStarted(ctx, run.id, run.checksum, run.info); err != nil {
+			return fmt.Errorf("Failed to record migration %s start: %w", run.id, err)
 		}
 	} else {
-		if err := store.RecordRollbackStarted(ctx, id); err != nil {
-			return fmt.Errorf("Failed to record migration %s rollback start: %v", id, err)
+		if err := store.RecordRollbackStarted(ctx, run.id); err != nil {
+			return fmt.Errorf("Failed to record migration %s rollback start: %w", run.id, err)
 		}
 	}
 	return nil
 }
 
-func execMigration(ctx context.Context, id string, contents string, conn db.Connection, up bool) error {
-	if err := conn.Exec(ctx, contents); err != nil {
-		return fmt.Errorf("Failed to execute %s migration %s: %v", direction(up), id, err)
+func withTimeoutOverride(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	overridden, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	stop := context.AfterFunc(ctx, func() {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cancel()
+		}
+	})
+	return overridden, func() {
+		stop()
+		cancel()
+	}
+}
+
+func execMigration(ctx context.Context, run *migrationRun, executor executor) error {
+	if setter, ok := executor.(db.StatementTimeoutSetter); ok && run.timeout > 0 {
+		if err := setter.SetStatementTimeout(ctx, run.timeout); err != nil {
+			return fmt.Errorf("Failed to set statement timeout for migration %s: %w", run.id, err)
+		}
+		defer setter.SetStatementTimeout(context.WithoutCancel(ctx), 0)
+	}
+	if err := executor.Exec(ctx, run.contents); err != nil {
+		return &apperrors.SQLFailure{Err: fmt.Errorf("Failed to execute %s migration %s: %w", direction(run.up), run.id, err)}
 	}
 	return nil
 }
 
-func finishMigration(ctx context.Context, id string, store db.AppliedMigrationStore, up bool) error {
-	if up {
-		if err := store.RecordFinished(ctx, id); err != nil {
-			return fmt.Errorf("Failed to record migration %s finish: %v", id, err)
+func finishMigration(ctx context.Context, run *migrationRun, store db.AppliedMigrationStore) error {
+	finishedAt := time.Now()
+	duration := finishedAt.Sub(run.startedAt)
+	if run.up {
+		if err := store.RecordFinished(ctx, run.id, duration); err != nil {
+			return fmt.Errorf("Failed to record migration %s finish: %w", run.id, err)
 		}
-		fmt.Printf("⬆️  Up migration %s ran successfully\n", id)
 	} else {
-		if err := store.Remove(ctx, id); err != nil {
-			return fmt.Errorf("Failed to record migration %s rollback finish: %v", id, err)
+		if err := store.Remove(ctx, run.id); err != nil {
+			return fmt.Errorf("Failed to record migration %s rollback finish: %w", run.id, err)
 		}
-		fmt.Printf("⬇️  Down migration %s ran successfully\n", id)
+	}
+	err := store.RecordHistory(ctx, db.HistoryEntry{
+		MigrationId: run.id,
+		Action:      direction(run.up),
+		Checksum:    run.checksum,
+		StartedAt:   run.startedAt,
+		FinishedAt:  finishedAt,
+		Duration:    duration,
+		RunInfo:     run.info,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record migration %s history: %w", run.id, err)
 	}
 	return nil
 }
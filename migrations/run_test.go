@@ -0,0 +1,424 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/db/sqlite"
+	"github.com/easynow112/dbkit/report"
+	"github.com/easynow112/dbkit/source/sourcetest"
+)
+
+func TestSelectRange(t *testing.T) {
+	cases := []struct {
+		name    string
+		applied []string
+		up      bool
+		opts    RunOptions
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "up to a target stops after the target",
+			applied: []string{"a"},
+			up:      true,
+			opts:    RunOptions{Target: "c"},
+			want:    []string{"b", "c"},
+		},
+		{
+			name:    "up to an already applied target runs nothing",
+			applied: []string{"a", "b"},
+			up:      true,
+			opts:    RunOptions{Target: "a"},
+			want:    []string{},
+		},
+		{
+			name:    "up to a target missing from source fails",
+			applied: []string{"a"},
+			up:      true,
+			opts:    RunOptions{Target: "z"},
+			wantErr: "migration z does not exist in source",
+		},
+		{
+			name:    "down to a target stops before the target",
+			applied: []string{"a", "b", "c", "d"},
+			opts:    RunOptions{Target: "b"},
+			want:    []string{"d", "c"},
+		},
+		{
+			name:    "down to a target that was never applied fails",
+			applied: []string{"a", "b"},
+			opts:    RunOptions{Target: "c"},
+			wantErr: "migration c has not been applied",
+		},
+		{
+			name:    "down to a target missing from source fails",
+			applied: []string{"a", "b"},
+			opts:    RunOptions{Target: "z"},
+			wantErr: "migration z does not exist in source",
+		},
+		{
+			name:    "steps limit the migrations run up to a target",
+			applied: []string{"a"},
+			up:      true,
+			opts:    RunOptions{Steps: 1, Target: "d"},
+			want:    []string{"b"},
+		},
+		{
+			name:    "target limits the migrations run within steps",
+			applied: []string{"a"},
+			up:      true,
+			opts:    RunOptions{Steps: 3, Target: "c"},
+			want:    []string{"b", "c"},
+		},
+		{
+			name:    "steps limit the migrations rolled back to a target",
+			applied: []string{"a", "b", "c", "d"},
+			opts:    RunOptions{Steps: 1, Target: "a"},
+			want:    []string{"d"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sourceStore := newSourceStore(t, "a", "b", "c", "d")
+			applied := appliedRows(t, sourceStore, c.applied...)
+			up, pending, err := selectRange(c.up, c.opts)(t.Context(), sourceStore, applied)
+			if c.wantErr != "" {
+				assertValidationError(t, err, c.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to select migrations: %v", err)
+			}
+			if up != c.up {
+				t.Fatalf("expected up to be %v", c.up)
+			}
+			if got := sourceIds(pending); !slices.Equal(got, c.want) {
+				t.Fatalf("expected pending %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectTarget(t *testing.T) {
+	cases := []struct {
+		name    string
+		applied []string
+		target  string
+		wantUp  bool
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "an unapplied target is migrated up to",
+			applied: []string{"a"},
+			target:  "c",
+			wantUp:  true,
+			want:    []string{"b", "c"},
+		},
+		{
+			name:    "an applied target is rolled back to",
+			applied: []string{"a", "b", "c", "d"},
+			target:  "b",
+			want:    []string{"d", "c"},
+		},
+		{
+			name:    "the latest applied target runs nothing",
+			applied: []string{"a", "b"},
+			target:  "b",
+			want:    []string{},
+		},
+		{
+			name:    "a target missing from source fails",
+			applied: []string{"a"},
+			target:  "z",
+			wantErr: "migration z does not exist in source",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sourceStore := newSourceStore(t, "a", "b", "c", "d")
+			applied := appliedRows(t, sourceStore, c.applied...)
+			up, pending, err := selectTarget(c.target, RunOptions{})(t.Context(), sourceStore, applied)
+			if c.wantErr != "" {
+				assertValidationError(t, err, c.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to select migrations: %v", err)
+			}
+			if up != c.wantUp {
+				t.Fatalf("expected up to be %v", c.wantUp)
+			}
+			if got := sourceIds(pending); !slices.Equal(got, c.want) {
+				t.Fatalf("expected pending %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutOverride(t *testing.T) {
+	t.Run("outlives the command deadline", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancelParent()
+		ctx, cancel := withTimeoutOverride(parent, 200*time.Millisecond)
+		defer cancel()
+		<-parent.Done()
+		if err := ctx.Err(); err != nil {
+			t.Fatalf("expected override to outlive the command deadline, got %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("expected override deadline to expire")
+		}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", ctx.Err())
+		}
+	})
+
+	t.Run("is cancelled with the command", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(t.Context())
+		ctx, cancel := withTimeoutOverride(parent, time.Minute)
+		defer cancel()
+		cancelParent()
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("expected override to be cancelled with the command")
+		}
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			t.Fatalf("expected cancelled, got %v", ctx.Err())
+		}
+	})
+
+	t.Run("is cancelled by its cancel func", func(t *testing.T) {
+		ctx, cancel := withTimeoutOverride(t.Context(), time.Minute)
+		cancel()
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			t.Fatalf("expected cancelled, got %v", ctx.Err())
+		}
+	})
+}
+
+type trxCountingDB struct {
+	db.DB
+	begun int
+}
+
+func (database *trxCountingDB) AcquireConnection(ctx context.Context) (db.Connection, error) {
+	conn, err := database.DB.AcquireConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &trxCountingConnection{Connection: conn, begun: &database.begun}, nil
+}
+
+type trxCountingConnection struct {
+	db.Connection
+	begun *int
+}
+
+func (conn *trxCountingConnection) BeginTrx(ctx context.Context) (db.Transaction, error) {
+	*conn.begun++
+	return conn.Connection.BeginTrx(ctx)
+}
+
+func TestRunWithoutTransaction(t *testing.T) {
+	cases := []struct {
+		name         string
+		up           string
+		wantBegun    int
+		wantFinished bool
+		wantErr      bool
+	}{
+		{
+			name:         "transactional migrations run in a transaction",
+			up:           "CREATE TABLE a (id INTEGER);",
+			wantBegun:    1,
+			wantFinished: true,
+		},
+		{
+			name:         "no-transaction migrations run outside a transaction",
+			up:           directivePrefix + "no-transaction\nCREATE INDEX CONCURRENTLY a_id ON a (id);",
+			wantFinished: true,
+		},
+		{
+			name:    "failed no-transaction migrations stay started",
+			up:      directivePrefix + "no-transaction\nINVALID_QUERY",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sources := sourcetest.NewStore()
+			if err := sources.Create(t.Context(), "a", fmt.Sprintf("%s\n%s\n%s\nDROP TABLE a;\n", upMarker, c.up, downMarker)); err != nil {
+				t.Fatalf("failed to create source: %v", err)
+			}
+			database, store := newTestDB(t, nil)
+			counting := &trxCountingDB{DB: database}
+
+			_, err := NewMigrator(counting, WithCombinedMigrationStore(sources)).Up(t.Context(), RunOptions{})
+			if c.wantErr {
+				var sqlFailure *apperrors.SQLFailure
+				if !errors.As(err, &sqlFailure) {
+					t.Fatalf("expected a SQL failure, got %T: %v", err, err)
+				}
+				if !strings.Contains(err.Error(), "ran outside a transaction, it may have been partially applied and require manual cleanup") {
+					t.Fatalf("expected the manual cleanup hint, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to migrate up: %v", err)
+			}
+			if counting.begun != c.wantBegun {
+				t.Fatalf("expected %d transactions, got %d", c.wantBegun, counting.begun)
+			}
+
+			applied, err := store.List(t.Context())
+			if err != nil {
+				t.Fatalf("failed to list applied migrations: %v", err)
+			}
+			if len(applied) != 1 || applied[0].Id != "a" {
+				t.Fatalf("expected a to be recorded, got %+v", applied)
+			}
+			if finished := applied[0].FinishedAt != nil; finished != c.wantFinished {
+				t.Fatalf("expected a to be finished: %v, got %+v", c.wantFinished, applied[0])
+			}
+		})
+	}
+}
+
+func TestApplyMigration(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		wantRow  bool
+	}{
+		{"a successful migration records its row", "CREATE TABLE a (id INTEGER);", true},
+		{"a failed migration rolls back its row with its changes", "CREATE TABLE a (id INTEGER);\nINSERT INTO missing (id) VALUES (1);", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			database, err := sqlite.NewDB(t.Context(), &config.DriverConfig{
+				Driver: "sqlite",
+				Config: map[string]string{"path": filepath.Join(t.TempDir(), "dbkit.sqlite")},
+			}, &config.GlobalConfig{})
+			if err != nil {
+				t.Fatalf("failed to create db: %v", err)
+			}
+			t.Cleanup(func() { database.Close() })
+			conn, err := database.AcquireConnection(t.Context())
+			if err != nil {
+				t.Fatalf("failed to acquire connection: %v", err)
+			}
+			t.Cleanup(func() { conn.Close() })
+			store := conn.AppliedMigrationStore()
+			if err := store.EnsureSchema(t.Context()); err != nil {
+				t.Fatalf("failed to ensure schema: %v", err)
+			}
+
+			err = applyMigration(t.Context(), &migrationRun{
+				id:        "a",
+				checksum:  "checksum",
+				contents:  c.contents,
+				up:        true,
+				startedAt: time.Now(),
+			}, conn)
+			if c.wantRow != (err == nil) {
+				t.Fatalf("expected migration to succeed: %v, got %v", c.wantRow, err)
+			}
+
+			applied, err := store.List(t.Context())
+			if err != nil {
+				t.Fatalf("failed to list applied migrations: %v", err)
+			}
+			if got := len(applied) == 1 && applied[0].Id == "a" && applied[0].FinishedAt != nil; got != c.wantRow {
+				t.Fatalf("expected a to be applied: %v, got %+v", c.wantRow, applied)
+			}
+			if !c.wantRow && len(applied) != 0 {
+				t.Fatalf("expected no applied rows after the failed migration, got %+v", applied)
+			}
+			history, err := store.History(t.Context())
+			if err != nil {
+				t.Fatalf("failed to list history: %v", err)
+			}
+			if (len(history) == 1) != c.wantRow {
+				t.Fatalf("expected history to match the applied rows, got %+v", history)
+			}
+			if err := conn.Exec(t.Context(), "SELECT id FROM a"); (err == nil) != c.wantRow {
+				t.Fatalf("expected table a to exist: %v, got %v", c.wantRow, err)
+			}
+		})
+	}
+}
+
+type ddlCommittingDB struct {
+	db.DB
+}
+
+func (database *ddlCommittingDB) AcquireConnection(ctx context.Context) (db.Connection, error) {
+	conn, err := database.DB.AcquireConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ddlCommittingConnection{Connection: conn}, nil
+}
+
+type ddlCommittingConnection struct {
+	db.Connection
+}
+
+func (conn *ddlCommittingConnection) CommitsDDL() bool {
+	return true
+}
+
+func TestWarnDDLCommits(t *testing.T) {
+	cases := []struct {
+		name         string
+		commitsDDL   bool
+		ups          []string
+		wantWarnings int
+	}{
+		{"transactional migrations warn once", true, []string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE b (id INTEGER);"}, 1},
+		{"no-transaction migrations do not warn", true, []string{directivePrefix + "no-transaction\nCREATE TABLE a (id INTEGER);"}, 0},
+		{"transactional DDL databases do not warn", false, []string{"CREATE TABLE a (id INTEGER);"}, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sources := sourcetest.NewStore()
+			for i, up := range c.ups {
+				id := fmt.Sprintf("%03d", i)
+				if err := sources.Create(t.Context(), id, fmt.Sprintf("%s\n%s\n%s\n", upMarker, up, downMarker)); err != nil {
+					t.Fatalf("failed to create source: %v", err)
+				}
+			}
+			database, _ := newTestDB(t, nil)
+			if c.commitsDDL {
+				database = &ddlCommittingDB{DB: database}
+			}
+			var warnings int
+			reporter := reporterFunc(func(event report.Event) {
+				if event.Kind == report.MigrationWarning {
+					warnings++
+				}
+			})
+
+			migrator := NewMigrator(database, WithCombinedMigrationStore(sources), WithReporter(reporter))
+			if _, err := migrator.Up(t.Context(), RunOptions{}); err != nil {
+				t.Fatalf("failed to migrate up: %v", err)
+			}
+			if warnings != c.wantWarnings {
+				t.Fatalf("expected %d warnings, got %d", c.wantWarnings, warnings)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	"github.com/easynow112/dbkit/apperrors"
 	"github.com/easynow112/dbkit/db"
 	"github.com/easynow112/dbkit/source"
 )
@@ -30,23 +31,36 @@ func (source *migrationSource) contents(ctx context.Context) (up string, down st
 	return upContents, downContents, checksum, nil
 }
 
+func (source *migrationSource) script(ctx context.Context, up bool) (contents string, checksum string, parsed directives, err error) {
+	upContents, downContents, checksum, err := source.contents(ctx)
+	if err != nil {
+		return "", "", directives{}, err
+	}
+	contents = downContents
+	if up {
+		contents = upContents
+	}
+	parsed, err = parseDirectives(contents)
+	if err != nil {
+		return "", "", directives{}, &apperrors.Validation{Err: fmt.Errorf("invalid %s migration %s: %w", direction(up), source.id, err)}
+	}
+	return contents, checksum, parsed, nil
+}
+
 func (source *migrationSource) validateApplication(ctx context.Context, applied db.AppliedMigration) error {
 	_, _, checksum, err := source.contents(ctx)
 	if err != nil {
 		return err
 	}
-	if !(source.up.Id == applied.Id && source.down.Id == applied.Id) {
-		return fmt.Errorf("applied migration id does not match source id: applied id = %s, up id = %s, down id = %s", applied.Id, source.up.Id, source.down.Id)
-	}
 	if checksum != applied.Checksum {
-		return fmt.Errorf("migration source corruption: %s has been altered since it was last applied", applied.Id)
+		return &apperrors.Validation{Err: fmt.Errorf("migration source corruption: %s has been altered since it was last applied", applied.Id)}
 	}
 	return nil
 }
 
 func newMigrationSource(upSource *source.Source, downSource *source.Source) (source *migrationSource, err error) {
 	if upSource.Id != downSource.Id {
-		return nil, fmt.Errorf("up/down source id mismatch: upSource.id = %s but downSource.id = %s", upSource.Id, downSource.Id)
+		return nil, &apperrors.Validation{Err: fmt.Errorf("up/down source id mismatch: upSource.id = %s but downSource.id = %s", upSource.Id, downSource.Id)}
 	}
 	return &migrationSource{
 		id:   upSource.Id,
@@ -4,16 +4,20 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/easynow112/dbkit/apperrors"
 	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/report"
 	"github.com/easynow112/dbkit/source"
 	"github.com/easynow112/dbkit/workers"
 )
 
 type migrationSourceStore struct {
-	upStore   source.Store
-	downStore source.Store
+	upStore       source.Store
+	downStore     source.Store
+	combinedStore source.Store
 }
 
 func (store *migrationSourceStore) validate(ctx context.Context) error {
@@ -22,6 +26,10 @@ func (store *migrationSourceStore) validate(ctx context.Context) error {
 }
 
 func (store *migrationSourceStore) list(ctx context.Context) ([]*migrationSource, error) {
+	if store.combinedStore != nil {
+		return store.listCombined(ctx)
+	}
+
 	upSources, err := store.upStore.List(ctx)
 	if err != nil {
 		return nil, err
@@ -35,14 +43,14 @@ func (store *migrationSourceStore) list(ctx context.Context) ([]*migrationSource
 	migrationSources := make([]*migrationSource, 0, len(upSources))
 
 	if len(upSources) != len(downSources) {
-		return nil, fmt.Errorf("migration stores corrupted: up store contains %d source(s) and down store contains %d source(s)", len(upSources), len(downSources))
+		return nil, &apperrors.Validation{Err: fmt.Errorf("migration stores corrupted: up store contains %d source(s) and down store contains %d source(s)", len(upSources), len(downSources))}
 	}
 
 	for i, upSource := range upSources {
 		downSource := downSources[i]
 		source, err := newMigrationSource(upSource, downSource)
 		if err != nil {
-			return nil, fmt.Errorf("migration source at index %d is corrupted: %v", i, err)
+			return nil, &apperrors.Validation{Err: fmt.Errorf("migration source at index %d is corrupted: %w", i, err)}
 		}
 		migrationSources = append(migrationSources, source)
 	}
@@ -50,52 +58,134 @@ func (store *migrationSourceStore) list(ctx context.Context) ([]*migrationSource
 	return migrationSources, nil
 }
 
-func (sourceStore *migrationSourceStore) GetPending(ctx context.Context, appliedMigrations []db.AppliedMigration, up bool) (pending []*migrationSource, err error) {
+func (store *migrationSourceStore) listCombined(ctx context.Context) ([]*migrationSource, error) {
+	combinedSources, err := store.combinedStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrationSources := make([]*migrationSource, 0, len(combinedSources))
+	for _, combinedSource := range combinedSources {
+		migrationSources = append(migrationSources, newCombinedMigrationSource(combinedSource))
+	}
+	return migrationSources, nil
+}
+
+func (sourceStore *migrationSourceStore) GetPending(ctx context.Context, appliedMigrations []db.AppliedMigration, up bool, allowOutOfOrder bool) (pending []*migrationSource, err error) {
 	sources, err := sourceStore.list(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, source := range sources {
-		if i < len(appliedMigrations) {
-			appliedMigration := appliedMigrations[i]
-			if err := source.validateApplication(ctx, appliedMigration); err != nil {
-				return nil, err
-			}
-			if !up {
-				pending = append(pending, source)
-			}
-			continue
-		}
-		if up {
-			pending = append(pending, source)
+	reconciled, err := reconcile(ctx, sources, appliedMigrations, allowOutOfOrder || !up)
+	if err != nil {
+		return nil, err
+	}
+
+	if up {
+		if allowOutOfOrder {
+			return sortById(append(reconciled.skipped, reconciled.unapplied...)), nil
 		}
+		return reconciled.unapplied, nil
+	}
+
+	sourcesById := make(map[string]*migrationSource, len(reconciled.applied))
+	for _, source := range reconciled.applied {
+		sourcesById[source.id] = source
 	}
-	if !up {
-		slices.Reverse(pending)
+	for _, appliedMigration := range slices.Backward(appliedMigrations) {
+		pending = append(pending, sourcesById[appliedMigration.Id])
 	}
 	return pending, nil
 }
 
-func (sourceStore *migrationSourceStore) Create(ctx context.Context, id string, content string) (err error) {
-	createUpJob := newCreateMigrationJob(sourceStore.upStore, "up", id, content)
-	createDownJob := newCreateMigrationJob(sourceStore.downStore, "down", id, content)
+type reconciliation struct {
+	applied   []*migrationSource
+	unapplied []*migrationSource
+	skipped   []*migrationSource
+}
 
-	rbCtx, cancelRb := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
-	defer cancelRb()
+func reconcile(ctx context.Context, sources []*migrationSource, appliedMigrations []db.AppliedMigration, allowOutOfOrder bool) (*reconciliation, error) {
+	appliedById := make(map[string]db.AppliedMigration, len(appliedMigrations))
+	for _, appliedMigration := range appliedMigrations {
+		appliedById[appliedMigration.Id] = appliedMigration
+	}
 
-	_, err = workers.RunJobsAtomically(ctx, rbCtx, []workers.ReversibleJob{createUpJob, createDownJob}, 2)
-	return err
+	reconciled := &reconciliation{}
+	sourceIds := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		sourceIds[source.id] = true
+		appliedMigration, ok := appliedById[source.id]
+		if !ok {
+			reconciled.unapplied = append(reconciled.unapplied, source)
+			continue
+		}
+		if err := source.validateApplication(ctx, appliedMigration); err != nil {
+			return nil, err
+		}
+		reconciled.skipped = append(reconciled.skipped, reconciled.unapplied...)
+		reconciled.unapplied = nil
+		reconciled.applied = append(reconciled.applied, source)
+	}
+
+	var missing []string
+	for _, appliedMigration := range appliedMigrations {
+		if !sourceIds[appliedMigration.Id] {
+			missing = append(missing, appliedMigration.Id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &apperrors.Validation{Err: fmt.Errorf("migration(s) applied to the database but missing from source: %s", strings.Join(missing, ", "))}
+	}
+
+	if len(reconciled.skipped) > 0 && !allowOutOfOrder {
+		return nil, &apperrors.Validation{Err: fmt.Errorf("missing older migrations: %s have not been applied but are older than the latest applied migration %s, enable allowOutOfOrder to apply them", joinIds(reconciled.skipped), reconciled.applied[len(reconciled.applied)-1].id)}
+	}
+
+	return reconciled, nil
+}
+
+func sortById(sources []*migrationSource) []*migrationSource {
+	slices.SortFunc(sources, func(a, b *migrationSource) int {
+		return strings.Compare(a.id, b.id)
+	})
+	return sources
 }
 
-func newMigrationSourceStore(ctx context.Context, upStore source.Store, downStore source.Store) (*migrationSourceStore, error) {
-	store := migrationSourceStore{
-		upStore:   upStore,
-		downStore: downStore,
+func joinIds(sources []*migrationSource) string {
+	ids := make([]string, 0, len(sources))
+	for _, source := range sources {
+		ids = append(ids, source.id)
 	}
-	err := store.validate(ctx)
+	return strings.Join(ids, ", ")
+}
+
+func (sourceStore *migrationSourceStore) find(ctx context.Context, id string) (*migrationSource, error) {
+	sources, err := sourceStore.list(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &store, nil
+	for _, source := range sources {
+		if source.id == id {
+			return source, nil
+		}
+	}
+	return nil, nil
+}
+
+func (sourceStore *migrationSourceStore) Create(ctx context.Context, id string, content string, reporter report.Reporter) (err error) {
+	if sourceStore.combinedStore != nil {
+		if content == "" {
+			content = combinedTemplate
+		}
+		return createMigration(ctx, sourceStore.combinedStore, "combined", id, content, reporter)
+	}
+
+	createUpJob := newCreateMigrationJob(sourceStore.upStore, "up", id, content, reporter)
+	createDownJob := newCreateMigrationJob(sourceStore.downStore, "down", id, content, reporter)
+
+	rbCtx, cancelRb := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancelRb()
+
+	_, err = workers.RunJobsAtomically(ctx, rbCtx, []workers.ReversibleJob{createUpJob, createDownJob}, 2)
+	return err
 }
@@ -0,0 +1,207 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/source/sourcetest"
+)
+
+func newSourceStore(t *testing.T, ids ...string) *migrationSourceStore {
+	t.Helper()
+	store := sourcetest.NewStore()
+	for _, id := range ids {
+		contents := fmt.Sprintf("%s\nCREATE TABLE %s (id INTEGER);\n%s\nDROP TABLE %s;\n", upMarker, id, downMarker, id)
+		if err := store.Create(t.Context(), id, contents); err != nil {
+			t.Fatalf("failed to create source %s: %v", id, err)
+		}
+	}
+	return &migrationSourceStore{combinedStore: store}
+}
+
+func appliedRows(t *testing.T, sourceStore *migrationSourceStore, ids ...string) []db.AppliedMigration {
+	t.Helper()
+	startedAt := time.Now().Add(-time.Hour)
+	rows := make([]db.AppliedMigration, 0, len(ids))
+	for i, id := range ids {
+		checksum := "missing"
+		source, err := sourceStore.find(t.Context(), id)
+		if err != nil {
+			t.Fatalf("failed to find source %s: %v", id, err)
+		}
+		if source != nil {
+			if _, _, checksum, err = source.contents(t.Context()); err != nil {
+				t.Fatalf("failed to read source %s: %v", id, err)
+			}
+		}
+		started := startedAt.Add(time.Duration(i) * time.Second)
+		finished := started.Add(time.Millisecond)
+		rows = append(rows, db.AppliedMigration{
+			Id:         id,
+			Checksum:   checksum,
+			StartedAt:  started,
+			FinishedAt: &finished,
+		})
+	}
+	return rows
+}
+
+func sourceIds(sources []*migrationSource) []string {
+	ids := make([]string, 0, len(sources))
+	for _, source := range sources {
+		ids = append(ids, source.id)
+	}
+	return ids
+}
+
+func assertValidationError(t *testing.T, err error, contains string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error containing %q", contains)
+	}
+	var validation *apperrors.Validation
+	if !errors.As(err, &validation) {
+		t.Fatalf("expected a validation error, got %T: %v", err, err)
+	}
+	if !strings.Contains(err.Error(), contains) {
+		t.Fatalf("expected error containing %q, got %v", contains, err)
+	}
+}
+
+func TestGetPending(t *testing.T) {
+	cases := []struct {
+		name            string
+		sources         []string
+		applied         []string
+		up              bool
+		allowOutOfOrder bool
+		want            []string
+		wantErr         string
+	}{
+		{
+			name:    "up returns migrations after the latest applied",
+			sources: []string{"a", "b", "c"},
+			applied: []string{"a"},
+			up:      true,
+			want:    []string{"b", "c"},
+		},
+		{
+			name:    "up rejects a gap before the latest applied migration",
+			sources: []string{"a", "b", "c", "d"},
+			applied: []string{"a", "c"},
+			up:      true,
+			wantErr: "missing older migrations: b have not been applied but are older than the latest applied migration c",
+		},
+		{
+			name:            "up includes a gap before the latest applied migration when out of order is allowed",
+			sources:         []string{"a", "b", "c", "d"},
+			applied:         []string{"a", "c"},
+			up:              true,
+			allowOutOfOrder: true,
+			want:            []string{"b", "d"},
+		},
+		{
+			name:    "up rejects an applied migration missing from source",
+			sources: []string{"a", "c"},
+			applied: []string{"a", "b", "c"},
+			up:      true,
+			wantErr: "migration(s) applied to the database but missing from source: b",
+		},
+		{
+			name:            "up rejects an applied migration missing from source when out of order is allowed",
+			sources:         []string{"a", "c"},
+			applied:         []string{"a", "b"},
+			up:              true,
+			allowOutOfOrder: true,
+			wantErr:         "missing from source: b",
+		},
+		{
+			name:    "down rejects an applied migration missing from source",
+			sources: []string{"a", "c"},
+			applied: []string{"a", "b", "c"},
+			wantErr: "missing from source: b",
+		},
+		{
+			name:    "down returns applied migrations newest first",
+			sources: []string{"a", "b", "c"},
+			applied: []string{"a", "b", "c"},
+			want:    []string{"c", "b", "a"},
+		},
+		{
+			name:    "down skips a gap before the latest applied migration",
+			sources: []string{"a", "b", "c"},
+			applied: []string{"a", "c"},
+			want:    []string{"c", "a"},
+		},
+		{
+			name:    "down follows the order migrations were applied in",
+			sources: []string{"a", "b", "c"},
+			applied: []string{"a", "c", "b"},
+			want:    []string{"b", "c", "a"},
+		},
+		{
+			name:    "down returns nothing when nothing is applied",
+			sources: []string{"a", "b"},
+			want:    []string{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sourceStore := newSourceStore(t, c.sources...)
+			applied := appliedRows(t, sourceStore, c.applied...)
+			pending, err := sourceStore.GetPending(t.Context(), applied, c.up, c.allowOutOfOrder)
+			if c.wantErr != "" {
+				assertValidationError(t, err, c.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get pending migrations: %v", err)
+			}
+			if got := sourceIds(pending); !slices.Equal(got, c.want) {
+				t.Fatalf("expected pending %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Run("rejects an applied migration whose source changed", func(t *testing.T) {
+		sourceStore := newSourceStore(t, "a", "b")
+		applied := appliedRows(t, sourceStore, "a", "b")
+		applied[1].Checksum = "changed"
+		sources, err := sourceStore.list(t.Context())
+		if err != nil {
+			t.Fatalf("failed to list sources: %v", err)
+		}
+		_, err = reconcile(t.Context(), sources, applied, false)
+		assertValidationError(t, err, "b has been altered since it was last applied")
+	})
+
+	t.Run("separates applied, skipped and unapplied migrations", func(t *testing.T) {
+		sourceStore := newSourceStore(t, "a", "b", "c", "d", "e")
+		applied := appliedRows(t, sourceStore, "a", "d", "c")
+		sources, err := sourceStore.list(t.Context())
+		if err != nil {
+			t.Fatalf("failed to list sources: %v", err)
+		}
+		reconciled, err := reconcile(t.Context(), sources, applied, true)
+		if err != nil {
+			t.Fatalf("failed to reconcile: %v", err)
+		}
+		if got := sourceIds(reconciled.applied); !slices.Equal(got, []string{"a", "c", "d"}) {
+			t.Fatalf("expected applied [a c d], got %v", got)
+		}
+		if got := sourceIds(reconciled.skipped); !slices.Equal(got, []string{"b"}) {
+			t.Fatalf("expected skipped [b], got %v", got)
+		}
+		if got := sourceIds(reconciled.unapplied); !slices.Equal(got, []string{"e"}) {
+			t.Fatalf("expected unapplied [e], got %v", got)
+		}
+	})
+}
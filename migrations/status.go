@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+type MigrationState string
+
+const (
+	StateApplied          MigrationState = "applied"
+	StatePending          MigrationState = "pending"
+	StateIncomplete       MigrationState = "started but unfinished"
+	StateRollingBack      MigrationState = "rollback in progress"
+	StateChecksumMismatch MigrationState = "checksum mismatch"
+	StateMissingSource    MigrationState = "missing from source"
+	StateSkipped          MigrationState = "skipped, older than an applied migration"
+)
+
+type MigrationStatus struct {
+	Id         string         `json:"id"`
+	State      MigrationState `json:"state"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+}
+
+func listAppliedMigrations(ctx context.Context, store db.AppliedMigrationStore) ([]db.AppliedMigration, error) {
+	exists, err := store.SchemaExists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to check applied migration schema: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	appliedMigrations, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list applied migrations: %w", err)
+	}
+	return appliedMigrations, nil
+}
+
+func migrationStatuses(ctx context.Context, sources []*migrationSource, appliedMigrations []db.AppliedMigration) ([]MigrationStatus, error) {
+	appliedById := make(map[string]db.AppliedMigration, len(appliedMigrations))
+	for _, applied := range appliedMigrations {
+		appliedById[applied.Id] = applied
+	}
+
+	statuses := make([]MigrationStatus, 0, len(sources))
+	for _, source := range sources {
+		applied, ok := appliedById[source.id]
+		if !ok {
+			statuses = append(statuses, MigrationStatus{
+				Id:    source.id,
+				State: StatePending,
+			})
+			continue
+		}
+		delete(appliedById, source.id)
+		for i := range statuses {
+			if statuses[i].State == StatePending {
+				statuses[i].State = StateSkipped
+			}
+		}
+
+		_, _, checksum, err := source.contents(ctx)
+		if err != nil {
+			return nil, err
+		}
+		status := appliedStatus(applied)
+		if status.State == StateApplied && checksum != applied.Checksum {
+			status.State = StateChecksumMismatch
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, applied := range appliedMigrations {
+		if _, ok := appliedById[applied.Id]; !ok {
+			continue
+		}
+		status := appliedStatus(applied)
+		status.State = StateMissingSource
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func appliedStatus(applied db.AppliedMigration) MigrationStatus {
+	startedAt := applied.StartedAt
+	status := MigrationStatus{
+		Id:         applied.Id,
+		State:      StateApplied,
+		StartedAt:  &startedAt,
+		FinishedAt: applied.FinishedAt,
+	}
+	if applied.FinishedAt == nil {
+		status.State = StateIncomplete
+	} else if applied.RollbackStartedAt != nil {
+		status.State = StateRollingBack
+	}
+	return status
+}
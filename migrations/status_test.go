@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/easynow112/dbkit/db"
+)
+
+func TestMigratorStatus(t *testing.T) {
+	sourceStore := newSourceStore(t, "a", "b", "c", "d", "e", "f")
+	rows := appliedRows(t, sourceStore, "a", "b", "d", "e", "z")
+	rows[1].Checksum = "changed"
+	rows[2].FinishedAt = nil
+	rollbackStartedAt := time.Now()
+	rows[3].RollbackStartedAt = &rollbackStartedAt
+	database, _ := newTestDB(t, rows)
+
+	statuses, err := NewMigrator(database, WithCombinedMigrationStore(sourceStore.combinedStore)).Status(t.Context())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	want := []struct {
+		id    string
+		state MigrationState
+	}{
+		{"a", StateApplied},
+		{"b", StateChecksumMismatch},
+		{"c", StateSkipped},
+		{"d", StateIncomplete},
+		{"e", StateRollingBack},
+		{"f", StatePending},
+		{"z", StateMissingSource},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), statuses)
+	}
+	for i, status := range statuses {
+		if status.Id != want[i].id || status.State != want[i].state {
+			t.Fatalf("expected status %d to be %s %q, got %s %q", i, want[i].id, want[i].state, status.Id, status.State)
+		}
+	}
+	for _, status := range statuses {
+		applied := slices.ContainsFunc(rows, func(row db.AppliedMigration) bool { return row.Id == status.Id })
+		if applied != (status.StartedAt != nil) {
+			t.Fatalf("expected %s to have a start time only when applied, got %v", status.Id, status.StartedAt)
+		}
+	}
+}
@@ -2,16 +2,249 @@ package msg
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 )
 
-var Usage = fmt.Sprintf("dbkit <command> [options]\n\nMigration commands:\n  %s\n  %s\n  %s\n\nSeed commands:\n  %s\n  %s", UsageMigrateNew, UsageMigrateUp, UsageMigrateDown, UsageSeed, UsageSeedNew)
+type Option struct {
+	Flag    string
+	Summary string
+}
 
-const UsageMigrateNew = "dbkit migrate new <name>     Create a new migration"
+type Options struct {
+	Title   string
+	Options []Option
+}
 
-const UsageMigrateUp = "dbkit migrate up [steps]     Apply pending migrations"
+type Command struct {
+	Use     string
+	Summary string
+	Options []*Options
+}
 
-const UsageMigrateDown = "dbkit migrate down [steps]   Roll back applied migrations"
+type Group struct {
+	Title    string
+	Use      string
+	Commands []*Command
+}
 
-const UsageSeedNew = "dbkit seed new <name>        Create a new seed"
+var RunOptions = &Options{
+	Title: "Run options",
+	Options: []Option{
+		{"--allow-out-of-order", "Apply unapplied migrations older than the latest applied migration"},
+		{"--note <text>", "Record a note in the history of every migration run"},
+	},
+}
 
-const UsageSeed = "dbkit seed                   Apply all seeds"
+var PlanOptions = &Options{
+	Title: "Plan options",
+	Options: []Option{
+		{"--dry-run", "Validate and print what would run without executing anything"},
+		{"--plan-file <path>", "Write the planned migrations or seeds to <path> as JSON"},
+	},
+}
+
+var GlobalOptions = &Options{
+	Title: "Global options",
+	Options: []Option{
+		{"--output <format>", "Output format, text (default) or json"},
+		{"--config <path>", "Use the config file at <path> instead of searching for dbkit.json, .yaml or .toml (or set DBKIT_CONFIG)"},
+		{"--database <name>", "Use the <name> database instead of active.database (or set DBKIT_DATABASE)"},
+		{"--env <name>", "Load the <name> environment instead of active.environment (or set DBKIT_ENV)"},
+		{"--source <name>", "Use the <name> combined migration source, or <up>,<down> sources, for migrate commands (or set DBKIT_SOURCE)"},
+		{"--timeout <d>", "Abort the command after <d> (default 30s, 0 for no limit), a migration with a \"-- dbkit:timeout=<d>\" directive gets its own limit instead"},
+		{"--lock-timeout <d>", "Wait up to <d> (e.g. 30s) for another migration process to release the lock"},
+		{"--help, -h", "Show help for a command"},
+	},
+}
+
+var rangeOptions = &Options{
+	Title: "Options",
+	Options: []Option{
+		{"--steps <n>", "Run at most <n> migrations, the same as passing [steps]"},
+		{"--to <id>", "Stop once migration <id> has run"},
+	},
+}
+
+var MigrateNew = &Command{
+	Use:     "dbkit migrate new <name>",
+	Summary: "Create a new migration",
+}
+
+var MigrateUp = &Command{
+	Use:     "dbkit migrate up [steps | --to <id>] [run options] [plan options]",
+	Summary: "Apply pending migrations",
+	Options: []*Options{rangeOptions, RunOptions, PlanOptions},
+}
+
+var MigrateDown = &Command{
+	Use:     "dbkit migrate down [steps | --to <id>] [plan options]",
+	Summary: "Roll back applied migrations",
+	Options: []*Options{rangeOptions, PlanOptions},
+}
+
+var MigrateGoto = &Command{
+	Use:     "dbkit migrate goto <id> [run options] [plan options]",
+	Summary: "Apply or roll back migrations until <id> is the latest applied",
+	Options: []*Options{RunOptions, PlanOptions},
+}
+
+var MigrateStatus = &Command{
+	Use:     "dbkit migrate status",
+	Summary: "Show the state of every migration",
+}
+
+var MigrateHistory = &Command{
+	Use:     "dbkit migrate history",
+	Summary: "Show every recorded migration run, rollback and repair",
+}
+
+var MigrateRepair = &Command{
+	Use:     "dbkit migrate repair [--yes] [action]",
+	Summary: "Repair incomplete migrations (applied, remove or retry)",
+	Options: []*Options{{
+		Title: "Options",
+		Options: []Option{
+			{"--yes", "Apply [action] to every dirty migration without prompting"},
+		},
+	}},
+}
+
+var LockStatus = &Command{
+	Use:     "dbkit lock status",
+	Summary: "Show whether the migration lock is held and by whom",
+}
+
+var LockRelease = &Command{
+	Use:     "dbkit lock release --force [--terminate]",
+	Summary: "Clear a stale migration lock, --terminate ends the session holding it",
+	Options: []*Options{{
+		Title: "Options",
+		Options: []Option{
+			{"--force", "Required, confirms the lock should be released"},
+			{"--terminate", "End the database session holding the lock (Postgres and MySQL)"},
+		},
+	}},
+}
+
+var Seed = &Command{
+	Use:     "dbkit seed [plan options]",
+	Summary: "Apply all seeds",
+	Options: []*Options{PlanOptions},
+}
+
+var SeedNew = &Command{
+	Use:     "dbkit seed new <name>",
+	Summary: "Create a new seed",
+}
+
+var Version = &Command{
+	Use:     "dbkit version",
+	Summary: "Print the dbkit version",
+}
+
+var Help = &Command{
+	Use:     "dbkit help [command]",
+	Summary: "Show help for a command",
+}
+
+var Migrate = &Group{
+	Title:    "Migration commands",
+	Use:      "dbkit migrate <command> [options]",
+	Commands: []*Command{MigrateNew, MigrateUp, MigrateDown, MigrateGoto, MigrateStatus, MigrateHistory, MigrateRepair},
+}
+
+var Lock = &Group{
+	Title:    "Lock commands",
+	Use:      "dbkit lock <command> [options]",
+	Commands: []*Command{LockStatus, LockRelease},
+}
+
+var Seeds = &Group{
+	Title:    "Seed commands",
+	Use:      "dbkit seed [command] [options]",
+	Commands: []*Command{Seed, SeedNew},
+}
+
+var Other = &Group{
+	Title:    "Other commands",
+	Commands: []*Command{Version, Help},
+}
+
+var ExitCodes = &Options{
+	Title: "Exit codes",
+	Options: []Option{
+		{"0", "Success"},
+		{"1", "Unexpected failure"},
+		{"2", "Invalid arguments"},
+		{"3", "The migration lock is held or was lost"},
+		{"4", "Invalid config, migration sources or database state"},
+		{"5", "A migration or seed failed to execute"},
+	},
+}
+
+var Usage = usage()
+
+func usage() string {
+	groups := []*Group{Migrate, Lock, Seeds, Other}
+	var commands []*Command
+	for _, group := range groups {
+		commands = append(commands, group.Commands...)
+	}
+	width := useWidth(commands)
+
+	var b strings.Builder
+	b.WriteString("dbkit <command> [options]")
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n\n%s:", group.Title)
+		writeCommands(&b, group.Commands, width)
+	}
+	writeOptions(&b, []*Options{RunOptions, PlanOptions, GlobalOptions})
+	writeOptions(&b, []*Options{ExitCodes})
+	b.WriteString("\n\nRun 'dbkit <command> --help' for details on a command")
+	return b.String()
+}
+
+func (c *Command) Help() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage:\n  %s\n\n%s", c.Use, c.Summary)
+	writeOptions(&b, slices.Concat(c.Options, []*Options{GlobalOptions}))
+	return b.String()
+}
+
+func (g *Group) Help() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage:\n  %s\n\n%s:", g.Use, g.Title)
+	writeCommands(&b, g.Commands, useWidth(g.Commands))
+	writeOptions(&b, []*Options{GlobalOptions})
+	return b.String()
+}
+
+func useWidth(commands []*Command) int {
+	width := 0
+	for _, command := range commands {
+		width = max(width, len(command.Use))
+	}
+	return width + 3
+}
+
+func writeCommands(b *strings.Builder, commands []*Command, width int) {
+	for _, command := range commands {
+		fmt.Fprintf(b, "\n  %-*s%s", width, command.Use, command.Summary)
+	}
+}
+
+func writeOptions(b *strings.Builder, groups []*Options) {
+	width := 0
+	for _, group := range groups {
+		for _, option := range group.Options {
+			width = max(width, len(option.Flag))
+		}
+	}
+	for _, group := range groups {
+		fmt.Fprintf(b, "\n\n%s:", group.Title)
+		for _, option := range group.Options {
+			fmt.Fprintf(b, "\n  %-*s%s", width+2, option.Flag, option.Summary)
+		}
+	}
+}
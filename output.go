@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/easynow112/dbkit/apperrors"
+	"github.com/easynow112/dbkit/migrations"
+	"github.com/easynow112/dbkit/report"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+type output struct {
+	format   string
+	w        io.Writer
+	prompt   io.Writer
+	reporter report.Reporter
+	encoder  *json.Encoder
+}
+
+func newOutput(format string) (*output, error) {
+	switch format {
+	case outputText:
+		return &output{
+			format:   format,
+			w:        os.Stdout,
+			prompt:   os.Stdout,
+			reporter: report.NewText(os.Stdout),
+		}, nil
+	case outputJSON:
+		return &output{
+			format:   format,
+			w:        os.Stdout,
+			prompt:   os.Stderr,
+			reporter: report.NewJSON(os.Stdout),
+			encoder:  json.NewEncoder(os.Stdout),
+		}, nil
+	}
+	return nil, fmt.Errorf("Invalid output format '%s', expected %s or %s", format, outputText, outputJSON)
+}
+
+func parseOutputFlag(args []string) (format string, rest []string, err error) {
+	format, found, rest, err := extractFlag(args, "output")
+	if err != nil {
+		return "", nil, fmt.Errorf("%v (%s or %s)", err, outputText, outputJSON)
+	}
+	if !found {
+		format = outputText
+	}
+	return format, rest, nil
+}
+
+func extractFlag(args []string, name string) (value string, found bool, rest []string, err error) {
+	rest = make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--"+name || arg == "-"+name:
+			if i+1 >= len(args) {
+				return "", false, nil, fmt.Errorf("Flag %s requires a value", arg)
+			}
+			value, found = args[i+1], true
+			i++
+		case strings.HasPrefix(arg, "--"+name+"="):
+			value, found = strings.TrimPrefix(arg, "--"+name+"="), true
+		case strings.HasPrefix(arg, "-"+name+"="):
+			value, found = strings.TrimPrefix(arg, "-"+name+"="), true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return value, found, rest, nil
+}
+
+func (out *output) json() bool {
+	return out.format == outputJSON
+}
+
+func (out *output) encode(event string, fields map[string]any) {
+	line := map[string]any{
+		"event": event,
+		"time":  time.Now(),
+	}
+	for key, value := range fields {
+		line[key] = value
+	}
+	_ = out.encoder.Encode(line)
+}
+
+func (out *output) begin() {
+	if !out.json() {
+		fmt.Fprintln(out.w)
+	}
+}
+
+func (out *output) end() {
+	if !out.json() {
+		fmt.Fprintln(out.w)
+	}
+}
+
+func (out *output) printHelp(help string) {
+	if out.json() {
+		out.encode("help", map[string]any{"help": help})
+		return
+	}
+	fmt.Fprintln(out.w, help)
+}
+
+func (out *output) printVersion(version string) {
+	if out.json() {
+		out.encode("version", map[string]any{"version": version})
+		return
+	}
+	fmt.Fprintf(out.w, "dbkit %s\n", version)
+}
+
+func (out *output) printError(err error) {
+	var errInvalidArgs *apperrors.InvalidArgs
+	if out.json() {
+		fields := map[string]any{"error": err.Error()}
+		if errors.As(err, &errInvalidArgs) {
+			fields["hint"] = errInvalidArgs.Hint
+		}
+		out.encode("error", fields)
+		return
+	}
+	if errors.As(err, &errInvalidArgs) {
+		fmt.Fprintln(out.w, errInvalidArgs.Error())
+		fmt.Fprintf(out.w, "Hint:\n%s\n", errInvalidArgs.Hint)
+	} else {
+		fmt.Fprintln(out.w, err)
+	}
+}
+
+func (out *output) reportResult(result *migrations.Result, err error) error {
+	if result == nil {
+		return err
+	}
+	out.printResult(result)
+	return err
+}
+
+func (out *output) printResult(result *migrations.Result) {
+	if out.json() {
+		out.encode("result", map[string]any{"result": result})
+		return
+	}
+	if result.DryRun {
+		result.Plan.Print(out.w)
+		return
+	}
+	if result.Direction != "" && len(result.Plan.Steps) == 0 {
+		fmt.Fprintf(out.w, "✅  No pending %s migrations\n", result.Direction)
+	}
+}
+
+func (out *output) printStatuses(statuses []migrations.MigrationStatus) {
+	if out.json() {
+		out.encode("status", map[string]any{"migrations": statuses})
+		return
+	}
+	if len(statuses) == 0 {
+		fmt.Fprintln(out.w, "✅  No migrations found")
+		return
+	}
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tSTARTED AT\tFINISHED AT")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.Id, status.State, formatTime(status.StartedAt), formatTime(status.FinishedAt))
+	}
+	w.Flush()
+}
+
+func (out *output) printHistory(history []migrations.HistoryEntry) {
+	if out.json() {
+		out.encode("history", map[string]any{"history": history})
+		return
+	}
+	if len(history) == 0 {
+		fmt.Fprintln(out.w, "✅  No migration history found")
+		return
+	}
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FINISHED AT\tID\tACTION\tDURATION\tAPPLIED BY\tHOST\tVERSION\tNOTE")
+	for _, entry := range history {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", formatTime(&entry.FinishedAt), entry.Id, entry.Action, entry.Duration, orDash(entry.AppliedBy), orDash(entry.Host), orDash(entry.Version), orDash(entry.Note))
+	}
+	w.Flush()
+}
+
+func (out *output) printLockStatus(status migrations.LockStatus) {
+	if out.json() {
+		out.encode("lock_status", map[string]any{"lock": status})
+		return
+	}
+	if !status.Held {
+		fmt.Fprintln(out.w, "🔓  Migration lock is not held")
+		return
+	}
+	fmt.Fprintln(out.w, "🔒  Migration lock is held")
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "USER\t%s\n", orDash(status.User))
+	fmt.Fprintf(w, "HOST\t%s\n", orDash(status.Host))
+	fmt.Fprintf(w, "PID\t%s\n", orDashInt(status.Pid))
+	fmt.Fprintf(w, "SINCE\t%s\n", formatTime(status.Since))
+	if status.Expires != nil {
+		fmt.Fprintf(w, "EXPIRES\t%s\n", formatTime(status.Expires))
+	}
+	if status.BackendPid != 0 {
+		fmt.Fprintf(w, "BACKEND PID\t%d\n", status.BackendPid)
+	}
+	w.Flush()
+}
+
+func (out *output) printLockReleased(status migrations.LockStatus) {
+	if out.json() {
+		out.encode("lock_released", map[string]any{"lock": status})
+		return
+	}
+	if !status.Held {
+		fmt.Fprintln(out.w, "✅  Migration lock was not held")
+		return
+	}
+	fmt.Fprintf(out.w, "✅  Released migration lock held by %s@%s (pid %s)\n", orDash(status.User), orDash(status.Host), orDashInt(status.Pid))
+}
+
+func orDashInt(value int) string {
+	if value == 0 {
+		return "-"
+	}
+	return strconv.Itoa(value)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func (out *output) printDirtyMigration(status migrations.MigrationStatus) {
+	if out.json() {
+		out.encode("dirty_migration", map[string]any{"migration": status})
+		return
+	}
+	fmt.Fprintf(out.w, "⚠️  Migration %s is %s (started at %s)\n", status.Id, status.State, formatTime(status.StartedAt))
+}
+
+func (out *output) printRepairResults(results []migrations.RepairResult, err error) {
+	if out.json() {
+		out.encode("repair", map[string]any{"results": results})
+		return
+	}
+	if len(results) == 0 && err == nil {
+		fmt.Fprintln(out.w, "✅  No dirty migrations to repair")
+		return
+	}
+	for _, result := range results {
+		switch result.Action {
+		case migrations.RepairApplied:
+			fmt.Fprintf(out.w, "✅  Migration %s marked as applied\n", result.Id)
+		case migrations.RepairRemove:
+			fmt.Fprintf(out.w, "✅  Migration %s removed from applied migrations\n", result.Id)
+		case migrations.RepairRetry:
+			if result.Up {
+				fmt.Fprintf(out.w, "✅  Migration %s retried and applied\n", result.Id)
+			} else {
+				fmt.Fprintf(out.w, "✅  Rollback of migration %s retried and completed\n", result.Id)
+			}
+		case migrations.RepairSkip:
+			fmt.Fprintf(out.w, "⏭️  Migration %s skipped\n", result.Id)
+		}
+	}
+}
+
+func (out *output) promptRepairAction(reader *bufio.Reader, id string, defaultAction migrations.RepairAction) (migrations.RepairAction, error) {
+	if defaultAction != "" {
+		fmt.Fprintf(out.prompt, "Apply '%s' to migration %s? [y/N]: ", defaultAction, id)
+		answer, err := readAnswer(reader)
+		if err != nil {
+			return "", err
+		}
+		if answer == "y" || answer == "yes" {
+			return defaultAction, nil
+		}
+		return migrations.RepairSkip, nil
+	}
+	for {
+		fmt.Fprint(out.prompt, "Mark as [a]pplied, [r]emove, re[t]ry or [s]kip: ")
+		answer, err := readAnswer(reader)
+		if err != nil {
+			return "", err
+		}
+		action, err := migrations.ParseRepairAction(answer)
+		if err == nil {
+			return action, nil
+		}
+		fmt.Fprintln(out.prompt, err)
+	}
+}
+
+func readAnswer(reader *bufio.Reader) (string, error) {
+	answer, err := reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && answer != "") {
+		return "", fmt.Errorf("Failed to read answer: %v", err)
+	}
+	return strings.ToLower(strings.TrimSpace(answer)), nil
+}
@@ -0,0 +1,63 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type Step struct {
+	Id            string        `json:"id"`
+	Direction     string        `json:"direction,omitempty"`
+	Transactional bool          `json:"transactional"`
+	Timeout       time.Duration `json:"timeoutNs,omitempty"`
+	SQL           string        `json:"sql"`
+}
+
+type Plan struct {
+	Kind  string `json:"kind"`
+	Steps []Step `json:"steps"`
+}
+
+func New(kind string) *Plan {
+	return &Plan{
+		Kind:  kind,
+		Steps: make([]Step, 0),
+	}
+}
+
+func (p *Plan) Add(step Step) {
+	p.Steps = append(p.Steps, step)
+}
+
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "📝  Dry run, %d %s(s) would run:\n", len(p.Steps), p.Kind)
+	for _, step := range p.Steps {
+		fmt.Fprintln(w)
+		if step.Direction != "" {
+			fmt.Fprintf(w, "-- %s (%s)\n", step.Id, step.Direction)
+		} else {
+			fmt.Fprintf(w, "-- %s\n", step.Id)
+		}
+		if !step.Transactional {
+			fmt.Fprintln(w, "-- runs outside a transaction")
+		}
+		if step.Timeout > 0 {
+			fmt.Fprintf(w, "-- times out after %s\n", step.Timeout)
+		}
+		fmt.Fprintln(w, step.SQL)
+	}
+}
+
+func (p *Plan) WriteFile(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write plan file %s: %w", path, err)
+	}
+	return nil
+}
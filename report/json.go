@@ -0,0 +1,49 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type JSONReporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+type jsonEvent struct {
+	Event      Kind      `json:"event"`
+	Time       time.Time `json:"time"`
+	Id         string    `json:"id,omitempty"`
+	Direction  string    `json:"direction,omitempty"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func NewJSON(w io.Writer) *JSONReporter {
+	return &JSONReporter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (r *JSONReporter) Report(event Event) {
+	line := jsonEvent{
+		Event:     event.Kind,
+		Time:      event.Time,
+		Id:        event.Id,
+		Direction: event.Direction,
+		Message:   event.Message,
+	}
+	if event.Duration > 0 {
+		durationMs := event.Duration.Milliseconds()
+		line.DurationMs = &durationMs
+	}
+	if event.Err != nil {
+		line.Error = event.Err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.encoder.Encode(line)
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"time"
+)
+
+type Kind string
+
+const (
+	LockWaiting           Kind = "lock_waiting"
+	LockAcquired          Kind = "lock_acquired"
+	LockLost              Kind = "lock_lost"
+	MigrationStarted      Kind = "migration_started"
+	MigrationFinished     Kind = "migration_finished"
+	MigrationFailed       Kind = "migration_failed"
+	MigrationWarning      Kind = "migration_warning"
+	MigrationCreated      Kind = "migration_created"
+	MigrationCreateFailed Kind = "migration_create_failed"
+	MigrationRemoved      Kind = "migration_removed"
+	MigrationRemoveFailed Kind = "migration_remove_failed"
+	SeedApplied           Kind = "seed_applied"
+	SeedFailed            Kind = "seed_failed"
+	SeedCreated           Kind = "seed_created"
+)
+
+type Event struct {
+	Kind      Kind
+	Time      time.Time
+	Id        string
+	Direction string
+	Duration  time.Duration
+	Message   string
+	Err       error
+}
+
+type Reporter interface {
+	Report(event Event)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(Event) {}
+
+var Discard Reporter = nopReporter{}
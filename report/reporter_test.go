@@ -0,0 +1,155 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var (
+	finished = Event{
+		Kind:      MigrationFinished,
+		Time:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Id:        "001_users",
+		Direction: "up",
+		Duration:  1500 * time.Millisecond,
+	}
+	failed = Event{
+		Kind:      MigrationFailed,
+		Time:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Id:        "002_posts",
+		Direction: "down",
+		Err:       errors.New("syntax error"),
+	}
+	warning = Event{
+		Kind:    MigrationWarning,
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: "DDL commits implicitly",
+	}
+)
+
+func TestTextReporter(t *testing.T) {
+	cases := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"finished up migrations", finished, "⬆️  Up migration 001_users ran successfully (1.5s)\n"},
+		{"finished down migrations", Event{Kind: MigrationFinished, Id: "001_users", Direction: "down", Duration: time.Second}, "⬇️  Down migration 001_users ran successfully (1s)\n"},
+		{"lock waits", Event{Kind: LockWaiting, Duration: time.Minute}, "⏳  Waiting up to 1m0s for another migration process to release the lock\n"},
+		{"lost locks", Event{Kind: LockLost, Err: errors.New("migration lock was lost")}, "❌  migration lock was lost, aborting\n"},
+		{"applied seeds", Event{Kind: SeedApplied, Id: "users", Duration: time.Second}, "✅  Seed users ran successfully (1s)\n"},
+		{"warnings", warning, "⚠️  DDL commits implicitly\n"},
+		{"failures are left to the caller", failed, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			NewText(&buf).Report(c.event)
+			if buf.String() != c.want {
+				t.Fatalf("expected %q, got %q", c.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	cases := []struct {
+		name  string
+		event Event
+		want  map[string]any
+	}{
+		{"finished migrations", finished, map[string]any{
+			"event":       "migration_finished",
+			"time":        "2026-01-02T03:04:05Z",
+			"id":          "001_users",
+			"direction":   "up",
+			"duration_ms": float64(1500),
+		}},
+		{"failed migrations", failed, map[string]any{
+			"event":     "migration_failed",
+			"time":      "2026-01-02T03:04:05Z",
+			"id":        "002_posts",
+			"direction": "down",
+			"error":     "syntax error",
+		}},
+		{"warnings", warning, map[string]any{
+			"event":   "migration_warning",
+			"time":    "2026-01-02T03:04:05Z",
+			"message": "DDL commits implicitly",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			NewJSON(&buf).Report(c.event)
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode %q: %v", buf.String(), err)
+			}
+			assertFields(t, got, c.want)
+		})
+	}
+}
+
+func TestSlogReporter(t *testing.T) {
+	cases := []struct {
+		name  string
+		event Event
+		want  map[string]any
+	}{
+		{"finished migrations", finished, map[string]any{
+			"level":     "INFO",
+			"msg":       "migration_finished",
+			"id":        "001_users",
+			"direction": "up",
+			"duration":  float64(1500 * time.Millisecond),
+		}},
+		{"failed migrations", failed, map[string]any{
+			"level":     "ERROR",
+			"msg":       "migration_failed",
+			"id":        "002_posts",
+			"direction": "down",
+			"error":     "syntax error",
+		}},
+		{"warnings", warning, map[string]any{
+			"level":   "WARN",
+			"msg":     "migration_warning",
+			"message": "DDL commits implicitly",
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if attr.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			})
+			NewSlog(slog.New(handler)).Report(c.event)
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode %q: %v", buf.String(), err)
+			}
+			assertFields(t, got, c.want)
+		})
+	}
+}
+
+func assertFields(t *testing.T, got map[string]any, want map[string]any) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected fields %v, got %v", want, got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("expected %s to be %v, got %v in %v", key, value, got[key], got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"context"
+	"log/slog"
+)
+
+type SlogReporter struct {
+	logger *slog.Logger
+}
+
+func NewSlog(logger *slog.Logger) *SlogReporter {
+	return &SlogReporter{
+		logger: logger,
+	}
+}
+
+func (r *SlogReporter) Report(event Event) {
+	attrs := make([]slog.Attr, 0, 5)
+	if event.Id != "" {
+		attrs = append(attrs, slog.String("id", event.Id))
+	}
+	if event.Direction != "" {
+		attrs = append(attrs, slog.String("direction", event.Direction))
+	}
+	if event.Duration > 0 {
+		attrs = append(attrs, slog.Duration("duration", event.Duration))
+	}
+	if event.Message != "" {
+		attrs = append(attrs, slog.String("message", event.Message))
+	}
+	level := slog.LevelInfo
+	if event.Kind == MigrationWarning {
+		level = slog.LevelWarn
+	}
+	if event.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", event.Err.Error()))
+	}
+	r.logger.LogAttrs(context.Background(), level, string(event.Kind), attrs...)
+}
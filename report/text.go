@@ -0,0 +1,49 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+type TextReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewText(w io.Writer) *TextReporter {
+	return &TextReporter{
+		w: w,
+	}
+}
+
+func (r *TextReporter) Report(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event.Kind {
+	case LockWaiting:
+		fmt.Fprintf(r.w, "⏳  Waiting up to %s for another migration process to release the lock\n", event.Duration)
+	case LockLost:
+		fmt.Fprintf(r.w, "❌  %v, aborting\n", event.Err)
+	case MigrationWarning:
+		fmt.Fprintf(r.w, "⚠️  %s\n", event.Message)
+	case MigrationFinished:
+		if event.Direction == "down" {
+			fmt.Fprintf(r.w, "⬇️  Down migration %s ran successfully (%s)\n", event.Id, event.Duration)
+		} else {
+			fmt.Fprintf(r.w, "⬆️  Up migration %s ran successfully (%s)\n", event.Id, event.Duration)
+		}
+	case MigrationCreated:
+		fmt.Fprintf(r.w, "✅ %s migration created successfully: %s\n", event.Direction, event.Id)
+	case MigrationCreateFailed:
+		fmt.Fprintf(r.w, "❌ error when creating %s migration:\n%v\n", event.Direction, event.Err)
+	case MigrationRemoved:
+		fmt.Fprintf(r.w, "%s migration removed successfully\n", event.Direction)
+	case MigrationRemoveFailed:
+		fmt.Fprintf(r.w, "❌ error when removing %s migration:\n%v\n", event.Direction, event.Err)
+	case SeedApplied:
+		fmt.Fprintf(r.w, "✅  Seed %s ran successfully (%s)\n", event.Id, event.Duration)
+	case SeedCreated:
+		fmt.Fprintf(r.w, "✅ seed created successfully: %s\n", event.Id)
+	}
+}
@@ -4,53 +4,28 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/easynow112/dbkit/config"
 	"github.com/easynow112/dbkit/db"
+	"github.com/easynow112/dbkit/plan"
 	"github.com/easynow112/dbkit/source"
 )
 
-func Run(ctx context.Context, cfg *config.Config, sourceStoreFactory source.StoreFactory, dbFactory db.DBFactory) error {
-
-	store, err := sourceStoreFactory(ctx, cfg, cfg.Active.Source.Seeds)
-	if err != nil {
-		return err
-	}
-	seeds, err := store.List(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to list seeds from store.\n%v", err)
-	}
-
-	db, err := dbFactory(ctx, cfg, cfg.Active.Database)
-	if err != nil {
-		return fmt.Errorf("Failed to load db driver.\n%v", err)
-	}
-	defer db.Close()
-
-	conn, err := db.AcquireConnection(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to aquire db connection: %v", err)
-	}
-	defer conn.Close()
-
-	lock, err := conn.TryAcquireLock(ctx)
-	if err != nil {
-		return fmt.Errorf("Failed to acquire lock: %v", err)
-	}
-	defer lock.Release(ctx)
-
+func NewPlan(ctx context.Context, seeds []*source.Source) (*plan.Plan, error) {
+	seedPlan := plan.New("seed")
 	for _, seed := range seeds {
-		err = execSeed(ctx, seed, conn)
+		contents, err := seed.Contents(ctx)
 		if err != nil {
-			return fmt.Errorf("Failed to execute seed %s: %v", seed.Id, err)
-		} else {
-			fmt.Printf("✅  Seed %s ran successfully\n", seed.Id)
+			return nil, err
 		}
+		seedPlan.Add(plan.Step{
+			Id:            seed.Id,
+			Transactional: true,
+			SQL:           contents,
+		})
 	}
-
-	return nil
+	return seedPlan, nil
 }
 
-func execSeed(ctx context.Context, source *source.Source, conn db.Connection) error {
+func Exec(ctx context.Context, source *source.Source, conn db.Connection) error {
 	contents, err := source.Contents(ctx)
 	if err != nil {
 		return err
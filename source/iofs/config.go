@@ -0,0 +1,19 @@
+package iofs
+
+import (
+	"github.com/easynow112/dbkit/config"
+)
+
+type Config struct {
+	Dir string
+}
+
+func newConfig(portConfig *config.DriverConfig) *Config {
+	dir, ok := portConfig.Config["dir"]
+	if !ok || dir == "" {
+		dir = "."
+	}
+	return &Config{
+		Dir: dir,
+	}
+}
@@ -0,0 +1,120 @@
+package iofs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/source"
+)
+
+var ErrReadOnly = errors.New("iofs source store is read only, sources must be added to the embedded file system at build time")
+
+type IOFSSourceStore struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (store *IOFSSourceStore) validate() error {
+	if !fs.ValidPath(store.dir) {
+		return fmt.Errorf("invalid path: %s", store.dir)
+	}
+	info, err := fs.Stat(store.fsys, store.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("directory does not exist: %s", store.dir)
+		}
+		return fmt.Errorf("failed to stat path: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("path is not a directory: %s", store.dir)
+	}
+	return nil
+}
+
+func NewIOFSSourceStore(fsys fs.FS, dir string) (*IOFSSourceStore, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("file system is nil")
+	}
+	store := IOFSSourceStore{fsys: fsys, dir: dir}
+	if err := store.validate(); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// NewFactory returns a source driver reading from fsys, typically an
+// embed.FS. It must be registered under the "iofs" driver name:
+//
+//	//go:embed migrations/*.sql
+//	var migrationsFS embed.FS
+//
+//	source.RegisterDriver("iofs", iofs.NewFactory(migrationsFS))
+//
+// Sources configured with "driver": "iofs" then read from the "dir" key of
+// their config, relative to the root of fsys.
+func NewFactory(fsys fs.FS) source.DriverFactory {
+	return func(_ context.Context, driverCfg *config.DriverConfig, _ *config.GlobalConfig) (source.Store, error) {
+		if driverCfg == nil {
+			return nil, fmt.Errorf("driver config is nil")
+		}
+		if driverCfg.Driver != "iofs" {
+			return nil, fmt.Errorf("invalid driver: %s", driverCfg.Driver)
+		}
+		return NewIOFSSourceStore(fsys, newConfig(driverCfg).Dir)
+	}
+}
+
+func (store *IOFSSourceStore) List(ctx context.Context) ([]*source.Source, error) {
+	entries, err := fs.ReadDir(store.fsys, store.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir: %w", err)
+	}
+	var sources []*source.Source
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+		id := strings.TrimSuffix(fileName, ".sql")
+		sources = append(sources, &source.Source{
+			Id:       id,
+			Contents: store.sourceContents(path.Join(store.dir, fileName)),
+		})
+	}
+
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Id < sources[j].Id
+	})
+
+	return sources, nil
+}
+
+func (store *IOFSSourceStore) sourceContents(filePath string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		data, err := fs.ReadFile(store.fsys, filePath)
+		if err != nil {
+			return "", fmt.Errorf("could not read file %s: %w", filePath, err)
+		}
+		return string(data), nil
+	}
+}
+
+func (store *IOFSSourceStore) Create(_ context.Context, id string, _ string) error {
+	return fmt.Errorf("could not create %s: %w", id, ErrReadOnly)
+}
+
+func (store *IOFSSourceStore) Remove(_ context.Context, id string) error {
+	return fmt.Errorf("could not remove %s: %w", id, ErrReadOnly)
+}
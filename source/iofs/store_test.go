@@ -0,0 +1,102 @@
+package iofs
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/easynow112/dbkit/config"
+	"github.com/easynow112/dbkit/source"
+)
+
+func newTestStore(t *testing.T, fsys fstest.MapFS, dir string) source.Store {
+	t.Helper()
+	store, err := NewFactory(fsys)(t.Context(), &config.DriverConfig{Driver: "iofs", Config: map[string]string{"dir": dir}}, &config.GlobalConfig{})
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestNewFactory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_init.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+	}
+
+	cases := []struct {
+		name      string
+		driverCfg *config.DriverConfig
+		wantErr   string
+	}{
+		{"nil config is rejected", nil, "driver config is nil"},
+		{"other drivers are rejected", &config.DriverConfig{Driver: "fs", Config: map[string]string{"dir": "migrations"}}, "invalid driver: fs"},
+		{"missing directories are rejected", &config.DriverConfig{Driver: "iofs", Config: map[string]string{"dir": "seeds"}}, "directory does not exist: seeds"},
+		{"files are rejected as directories", &config.DriverConfig{Driver: "iofs", Config: map[string]string{"dir": "migrations/001_init.sql"}}, "path is not a directory"},
+		{"iofs config is accepted", &config.DriverConfig{Driver: "iofs", Config: map[string]string{"dir": "migrations"}}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewFactory(fsys)(t.Context(), c.driverCfg, &config.GlobalConfig{})
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("failed to create store: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	store := newTestStore(t, fstest.MapFS{
+		"migrations/002_posts.sql":        {Data: []byte("CREATE TABLE posts (id INTEGER);")},
+		"migrations/001_users.sql":        {Data: []byte("CREATE TABLE users (id INTEGER);")},
+		"migrations/README.md":            {Data: []byte("not a migration")},
+		"migrations/nested/003_tags.sql":  {Data: []byte("CREATE TABLE tags (id INTEGER);")},
+		"migrations/001_users.sql.backup": {Data: []byte("not a migration")},
+	}, "migrations")
+
+	sources, err := store.List(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list sources: %v", err)
+	}
+	var ids []string
+	for _, source := range sources {
+		ids = append(ids, source.Id)
+	}
+	if want := []string{"001_users", "002_posts"}; !slices.Equal(ids, want) {
+		t.Fatalf("expected sources %v, got %v", want, ids)
+	}
+	contents, err := sources[0].Contents(t.Context())
+	if err != nil {
+		t.Fatalf("failed to read source: %v", err)
+	}
+	if contents != "CREATE TABLE users (id INTEGER);" {
+		t.Fatalf("expected contents of 001_users.sql, got %q", contents)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	store := newTestStore(t, fstest.MapFS{
+		"migrations/001_users.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+	}, "migrations")
+
+	if err := store.Create(t.Context(), "002_posts", "CREATE TABLE posts (id INTEGER);"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected create to fail with ErrReadOnly, got %v", err)
+	}
+	if err := store.Remove(t.Context(), "001_users"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected remove to fail with ErrReadOnly, got %v", err)
+	}
+	sources, err := store.List(t.Context())
+	if err != nil {
+		t.Fatalf("failed to list sources: %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("expected sources to be unchanged, got %d", len(sources))
+	}
+}
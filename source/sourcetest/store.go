@@ -0,0 +1,38 @@
+// Package sourcetest provides an in-memory source.Store for tests.
+package sourcetest
+
+import (
+	"context"
+	"slices"
+
+	"github.com/easynow112/dbkit/source"
+)
+
+type Store struct {
+	sources []*source.Source
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+func (store *Store) List(ctx context.Context) ([]*source.Source, error) {
+	return store.sources, nil
+}
+
+func (store *Store) Remove(ctx context.Context, id string) error {
+	store.sources = slices.DeleteFunc(store.sources, func(source *source.Source) bool {
+		return source.Id == id
+	})
+	return nil
+}
+
+func (store *Store) Create(ctx context.Context, id string, contents string) error {
+	store.sources = append(store.sources, &source.Source{
+		Id: id,
+		Contents: func(ctx context.Context) (string, error) {
+			return contents, nil
+		},
+	})
+	return nil
+}
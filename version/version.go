@@ -0,0 +1,17 @@
+package version
+
+import (
+	"runtime/debug"
+)
+
+var Version = ""
+
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}